@@ -4,8 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/newrelic/dkenv/lib"
+
+	log "github.com/Sirupsen/logrus"
 	"github.com/mitchellh/go-homedir"
 )
 
@@ -13,6 +17,17 @@ type Cli struct {
 	BinDir   *string
 	HomeDir  *string
 	DkenvDir *string
+
+	// Optional; defaults depend on where the store ends up (see handleDkenvDir)
+	CacheDir *string
+
+	// Fall back to ~/.local/bin if BinDir is missing or not writable
+	Rootless bool
+
+	// Whether the command is going to link docker into BinDir. Only then is
+	// ~/.local/bin created; other commands only fall back to it if it
+	// exists.
+	Link bool
 }
 
 func New(binDir, homeDir, dkenvDir *string) *Cli {
@@ -20,6 +35,7 @@ func New(binDir, homeDir, dkenvDir *string) *Cli {
 		BinDir:   binDir,
 		HomeDir:  homeDir,
 		DkenvDir: dkenvDir,
+		CacheDir: new(string),
 	}
 
 	return c
@@ -70,12 +86,36 @@ func (c *Cli) setHomeDir() error {
 }
 
 func (c *Cli) handleBinDir() error {
+	*c.BinDir = ExpandPath(*c.BinDir, *c.HomeDir)
+
 	// Ensure it exists
 	isDir, err := IsDirAndExists(*c.BinDir)
 	if err != nil {
 		return fmt.Errorf("Bin directory error: %v", err)
 	}
 
+	if c.Rootless && (!isDir || lib.CheckWritable(*c.BinDir) != nil) {
+		userBinDir := UserBinDir(*c.HomeDir)
+
+		exists, err := IsDirAndExists(userBinDir)
+		if err != nil {
+			return fmt.Errorf("User bin directory error: %v", err)
+		}
+
+		if c.Link {
+			if err := os.MkdirAll(userBinDir, 0755); err != nil {
+				return fmt.Errorf("Error creating user bin dir (%v): %v", userBinDir, err)
+			}
+
+			log.Infof("%v is not writable - using %v instead", *c.BinDir, userBinDir)
+		}
+
+		if c.Link || exists {
+			*c.BinDir = userBinDir
+			return nil
+		}
+	}
+
 	if !isDir {
 		return errors.New("Bin directory does not exist!")
 	}
@@ -86,31 +126,98 @@ func (c *Cli) handleBinDir() error {
 	return nil
 }
 
-// Check if dkenv dir exists; if not, create it
+// Work out the dkenv (store) and cache dirs and create them if needed. An
+// unset dkenv dir means an existing ~/.dkenv, or $XDG_DATA_HOME/dkenv if
+// there is none. The cache defaults to $XDG_CACHE_HOME/dkenv for an XDG
+// store, and lives in the dkenv dir otherwise.
 func (c *Cli) handleDkenvDir() error {
-	// Expand the dir
-	if *c.DkenvDir == "~/.dkenv" {
-		*c.DkenvDir = *c.HomeDir + "/.dkenv"
+	if *c.DkenvDir == "" {
+		*c.DkenvDir = DefaultDkenvDir(*c.HomeDir)
 	}
 
-	isDir, err := IsDirAndExists(*c.DkenvDir)
-	if err != nil {
-		return fmt.Errorf("Dkenv directory error: %v", err)
+	*c.DkenvDir = filepath.Clean(ExpandPath(*c.DkenvDir, *c.HomeDir))
+	xdg := *c.DkenvDir == xdgDataDir(*c.HomeDir)
+
+	if err := ensureDir(*c.DkenvDir); err != nil {
+		return fmt.Errorf("Error creating dkenv dir (%v): %v", *c.DkenvDir, err)
 	}
 
-	if !isDir {
-		// Create the dir
-		if err := os.Mkdir(*c.DkenvDir, 0700); err != nil {
-			return fmt.Errorf("Error creating dkenv dir (%v): %v", *c.DkenvDir, err)
-		}
+	switch {
+	case *c.CacheDir != "":
+		*c.CacheDir = ExpandPath(*c.CacheDir, *c.HomeDir)
+	case xdg:
+		*c.CacheDir = filepath.Join(xdgDir("XDG_CACHE_HOME", *c.HomeDir, ".cache"), "dkenv")
+	default:
+		*c.CacheDir = *c.DkenvDir
 	}
 
-	// Strip trailing slash
-	*c.DkenvDir = strings.TrimRight(*c.DkenvDir, "/")
+	if err := ensureDir(*c.CacheDir); err != nil {
+		return fmt.Errorf("Error creating cache dir (%v): %v", *c.CacheDir, err)
+	}
+
+	*c.CacheDir = filepath.Clean(*c.CacheDir)
 
 	return nil
 }
 
+// Store location when none is configured: a pre-existing ~/.dkenv (for
+// compatibility), or the XDG data dir
+func DefaultDkenvDir(homeDir string) string {
+	legacy := filepath.Join(homeDir, ".dkenv")
+
+	if isDir, _ := IsDirAndExists(legacy); isDir {
+		return legacy
+	}
+
+	return xdgDataDir(homeDir)
+}
+
+func xdgDataDir(homeDir string) string {
+	return filepath.Join(xdgDir("XDG_DATA_HOME", homeDir, ".local/share"), "dkenv")
+}
+
+// Value of an XDG base directory variable, or its default under homeDir.
+// Relative paths are invalid according to the spec and get ignored.
+func xdgDir(env, homeDir, fallback string) string {
+	if dir := os.Getenv(env); dir != "" && filepath.IsAbs(dir) {
+		return dir
+	}
+
+	return filepath.Join(homeDir, fallback)
+}
+
+// Expand a leading ~ to homeDir
+func ExpandPath(path, homeDir string) string {
+	if path == "~" {
+		return homeDir
+	}
+
+	if strings.HasPrefix(path, "~/") {
+		return homeDir + path[1:]
+	}
+
+	return path
+}
+
+// Create dir (and its parents) if it doesn't exist
+func ensureDir(dir string) error {
+	isDir, err := IsDirAndExists(dir)
+	if err != nil {
+		return err
+	}
+
+	if isDir {
+		return nil
+	}
+
+	return os.MkdirAll(dir, 0700)
+}
+
+// Bin dir for installs without root privileges
+func UserBinDir(homeDir string) string {
+	return filepath.Join(homeDir, ".local", "bin")
+}
+
 func IsDirAndExists(dir string) (bool, error) {
 	fi, err := os.Stat(dir)
 	if err != nil && os.IsNotExist(err) {
@@ -128,3 +235,29 @@ func IsDirAndExists(dir string) (bool, error) {
 
 	return true, nil
 }
+
+// Make a lone "-" argument of a command reach it as an argument: the parser
+// takes "-" for a short flag, so "--" is put in front of it (eg.
+// "use -" becomes "use -- -")
+func EscapeDashArg(args []string, command string) []string {
+	escaped := make([]string, 0, len(args)+1)
+	seenCommand := false
+
+	for i, arg := range args {
+		if arg == "--" {
+			return append(escaped, args[i:]...)
+		}
+
+		if arg == command {
+			seenCommand = true
+		}
+
+		if arg == "-" && seenCommand {
+			escaped = append(escaped, "--")
+		}
+
+		escaped = append(escaped, arg)
+	}
+
+	return escaped
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/mitchellh/go-homedir"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 var (
@@ -21,7 +22,7 @@ func init() {
 	// setup dummy dirs
 	tmpDir, err := ioutil.TempDir("", ".dkenv")
 	if err != nil {
-		fmt.Println("Unable to create tmp dir for testing: %v", err)
+		fmt.Printf("Unable to create tmp dir for testing: %v\n", err)
 		os.Exit(1)
 	}
 
@@ -84,6 +85,53 @@ func TestHandleBinDir(t *testing.T) {
 	assert.Equal(t, testBinDir, *c2.BinDir)
 }
 
+func TestHandleBinDirRootless(t *testing.T) {
+	tmpHomeDir, err := ioutil.TempDir("", "dkenv_temp_home_dir")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir for testing: %v", err)
+	}
+	defer cleanUp(tmpHomeDir)
+
+	// Missing bin dir: fall back to ~/.local/bin, creating it
+	badDir := fmt.Sprintf("/tmp/bad_dir_%v", rand.Intn(99999))
+
+	// Commands which don't link leave ~/.local/bin alone while it doesn't
+	// exist
+	c0 := New(&badDir, &tmpHomeDir, &testDkenvDir)
+	c0.Rootless = true
+
+	assert.Error(t, c0.handleBinDir())
+
+	isDir, _ := IsDirAndExists(tmpHomeDir + "/.local/bin")
+	assert.False(t, isDir)
+
+	badDir1 := badDir
+
+	c1 := New(&badDir1, &tmpHomeDir, &testDkenvDir)
+	c1.Rootless = true
+	c1.Link = true
+
+	assert.NoError(t, c1.handleBinDir())
+	assert.Equal(t, tmpHomeDir+"/.local/bin", *c1.BinDir)
+
+	isDir, _ = IsDirAndExists(*c1.BinDir)
+	assert.True(t, isDir)
+
+	// Once it exists, they use it too
+	assert.NoError(t, c0.handleBinDir())
+	assert.Equal(t, tmpHomeDir+"/.local/bin", *c0.BinDir)
+
+	// Writable bin dir is used as is
+	binDir := tmpHomeDir + "/bin"
+	os.Mkdir(binDir, 0755)
+
+	c2 := New(&binDir, &tmpHomeDir, &testDkenvDir)
+	c2.Rootless = true
+
+	assert.NoError(t, c2.handleBinDir())
+	assert.Equal(t, tmpHomeDir+"/bin", *c2.BinDir)
+}
+
 func TestHandleDkenvDir(t *testing.T) {
 	// Create a tmp home dir
 	tmpHomeDir, err := ioutil.TempDir("", "dkenv_temp_home_dir")
@@ -128,6 +176,65 @@ func TestHandleDkenvDir(t *testing.T) {
 	assert.Contains(t, err3.Error(), "Error creating dkenv dir")
 }
 
+func TestHandleDkenvDirXDG(t *testing.T) {
+	tmpHomeDir, err := ioutil.TempDir("", "dkenv_temp_home_dir")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir for testing: %v", err)
+	}
+	defer cleanUp(tmpHomeDir)
+
+	for _, name := range []string{"XDG_DATA_HOME", "XDG_CACHE_HOME"} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Unsetenv(name)
+	}
+
+	// No ~/.dkenv: XDG defaults
+	var dkenvDir1 string
+
+	c1 := New(&testBinDir, &tmpHomeDir, &dkenvDir1)
+	assert.NoError(t, c1.handleDkenvDir())
+	assert.Equal(t, tmpHomeDir+"/.local/share/dkenv", *c1.DkenvDir)
+	assert.Equal(t, tmpHomeDir+"/.cache/dkenv", *c1.CacheDir)
+
+	// XDG variables are honoured; relative ones are ignored
+	os.Setenv("XDG_DATA_HOME", tmpHomeDir+"/data")
+	os.Setenv("XDG_CACHE_HOME", "relative/cache")
+
+	var dkenvDir2 string
+
+	c2 := New(&testBinDir, &tmpHomeDir, &dkenvDir2)
+	assert.NoError(t, c2.handleDkenvDir())
+	assert.Equal(t, tmpHomeDir+"/data/dkenv", *c2.DkenvDir)
+	assert.Equal(t, tmpHomeDir+"/.cache/dkenv", *c2.CacheDir)
+
+	// An existing ~/.dkenv wins, and keeps its cache
+	assert.NoError(t, os.Mkdir(tmpHomeDir+"/.dkenv", 0700))
+
+	var dkenvDir3 string
+
+	c3 := New(&testBinDir, &tmpHomeDir, &dkenvDir3)
+	assert.NoError(t, c3.handleDkenvDir())
+	assert.Equal(t, tmpHomeDir+"/.dkenv", *c3.DkenvDir)
+	assert.Equal(t, tmpHomeDir+"/.dkenv", *c3.CacheDir)
+
+	// Tilde expansion for any path
+	dkenvDir4 := "~/store"
+	cacheDir4 := "~/cache/"
+
+	c4 := New(&testBinDir, &tmpHomeDir, &dkenvDir4)
+	c4.CacheDir = &cacheDir4
+	assert.NoError(t, c4.handleDkenvDir())
+	assert.Equal(t, tmpHomeDir+"/store", *c4.DkenvDir)
+	assert.Equal(t, tmpHomeDir+"/cache", *c4.CacheDir)
+}
+
+func TestExpandPath(t *testing.T) {
+	assert.Equal(t, "/home/u", ExpandPath("~", "/home/u"))
+	assert.Equal(t, "/home/u/bin", ExpandPath("~/bin", "/home/u"))
+	assert.Equal(t, "~other/bin", ExpandPath("~other/bin", "/home/u"))
+	assert.Equal(t, "/usr/local/bin", ExpandPath("/usr/local/bin", "/home/u"))
+}
+
 func TestHandleArgs(t *testing.T) {
 	homeDir, homeDirErr := homedir.Dir()
 	assert.NoError(t, homeDirErr)
@@ -194,7 +301,32 @@ func TestIsDirAndExists(t *testing.T) {
 
 func cleanUp(tmpDir string) {
 	if err := os.RemoveAll(tmpDir); err != nil {
-		fmt.Println("Unable to clean up tmp dir: %v", err)
+		fmt.Printf("Unable to clean up tmp dir: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+func TestEscapeDashArg(t *testing.T) {
+	app := kingpin.New("dkenv", "")
+	app.Flag("bindir", "").String()
+	use := app.Command("use", "")
+	useArg := use.Arg("version", "").Required().String()
+
+	// Unescaped, kingpin takes "-" for a short flag
+	_, err := app.Parse([]string{"--bindir", "/tmp", "use", "-"})
+	assert.Error(t, err)
+
+	for _, args := range [][]string{
+		{"--bindir", "/tmp", "use", "-"},
+		{"use", "--", "-"},
+	} {
+		command, err := app.Parse(EscapeDashArg(args, "use"))
+		assert.NoError(t, err)
+		assert.Equal(t, "use", command)
+		assert.Equal(t, "-", *useArg)
+	}
+
+	// Other arguments are left alone
+	assert.Equal(t, []string{"use", "1.9.1"}, EscapeDashArg([]string{"use", "1.9.1"}, "use"))
+	assert.Equal(t, []string{"list", "-"}, EscapeDashArg([]string{"list", "-"}, "use"))
+}
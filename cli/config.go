@@ -0,0 +1,555 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/newrelic/dkenv/lib"
+)
+
+const (
+	CONFIG_FILE         = lib.CONFIG_FILE
+	PROJECT_CONFIG_FILE = ".dkenv.yaml"
+	SYSTEM_CONFIG_FILE  = "/etc/dkenv/config.yaml"
+
+	// Where a setting's value came from, in order of precedence
+	SOURCE_FLAG    = "flag"
+	SOURCE_ENV     = "env"
+	SOURCE_PROJECT = "project"
+	SOURCE_USER    = "user"
+	SOURCE_SYSTEM  = "system"
+	SOURCE_DEFAULT = "default"
+)
+
+// A setting which can be configured through a config file or the environment
+type Option struct {
+	Key     string
+	Env     string
+	Default string
+
+	// Accepted values; anything goes if empty
+	Choices []string
+
+	// May be set in a project's .dkenv.yaml. Repositories are cloned from
+	// anywhere, so only settings which can't make dkenv run or download
+	// something else are allowed there.
+	Project bool
+
+	// Relative values in config files are resolved against the file's
+	// directory
+	Path bool
+}
+
+var (
+	Options = []*Option{
+		{Key: "bindir", Env: "DKENV_BINDIR", Default: "/usr/local/bin", Path: true},
+		{Key: "dkenvdir", Env: "DKENV_DIR", Path: true},
+		{Key: "cachedir", Env: "DKENV_CACHE_DIR", Path: true},
+		{Key: "homedir", Env: "DKENV_HOMEDIR", Path: true},
+		{Key: "debug", Env: "DKENV_DEBUG", Default: "false", Choices: []string{"true", "false"}, Project: true},
+		{Key: "mirrors", Env: "DKENV_MIRRORS", Default: lib.DEFAULT_MIRROR},
+		{Key: "channel", Env: "DKENV_CHANNEL", Default: lib.CHANNEL_STABLE, Choices: lib.Channels, Project: true},
+		{Key: "channel_url", Env: "DKENV_CHANNEL_URL", Default: lib.DEFAULT_CHANNEL_URL},
+		{Key: "proxy", Env: "DKENV_PROXY"},
+		{Key: "default_version", Env: "DKENV_DEFAULT_VERSION", Project: true},
+		{Key: "user_mode", Env: "DKENV_USER_MODE", Default: "false", Choices: []string{"true", "false"}},
+		{Key: "sudo", Env: "DKENV_SUDO", Default: "false", Choices: []string{"true", "false"}},
+		{Key: "link_mode", Env: "DKENV_LINK_MODE", Default: lib.LINK_SYMLINK, Choices: lib.LinkModes},
+	}
+)
+
+// Effective value of a setting
+type Setting struct {
+	Key    string
+	Value  string
+	Source string // One of the SOURCE_* constants
+	Origin string // Flag, env var or file the value was read from
+}
+
+type Config struct {
+	settings map[string]*Setting
+	workDir  string
+}
+
+// Config with every setting at its default value
+func DefaultConfig() *Config {
+	c := &Config{settings: make(map[string]*Setting)}
+
+	for _, o := range Options {
+		c.settings[o.Key] = &Setting{Key: o.Key, Value: o.Default, Source: SOURCE_DEFAULT}
+	}
+
+	return c
+}
+
+// Work out every setting's value. Precedence is flag > env > project
+// (nearest .dkenv.yaml walking up from workDir) > user > system > default.
+// flags holds the values of command line flags; empty means not given.
+func LoadConfig(flags map[string]string, workDir string) (*Config, error) {
+	c := DefaultConfig()
+	c.workDir = workDir
+
+	// Lowest precedence first, so later layers override earlier ones
+	files := []struct{ source, path string }{
+		{SOURCE_SYSTEM, SYSTEM_CONFIG_FILE},
+		{SOURCE_USER, UserConfigPath()},
+		{SOURCE_PROJECT, ProjectConfigPath(workDir)},
+	}
+
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+
+		values, err := readConfigFile(f.path)
+		if err != nil && os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for key, value := range values {
+			if f.source == SOURCE_PROJECT {
+				if err := checkProjectSetting(key); err != nil {
+					return nil, fmt.Errorf("Invalid config file '%v': %v", f.path, err)
+				}
+			}
+
+			if o := findOption(key); o != nil && o.Path {
+				value = resolveConfigPath(value, f.path)
+			}
+
+			if err := c.set(key, value, f.source, f.path); err != nil {
+				return nil, fmt.Errorf("Invalid config file '%v': %v", f.path, err)
+			}
+		}
+	}
+
+	for _, o := range Options {
+		if value, ok := os.LookupEnv(o.Env); ok && value != "" {
+			if err := c.set(o.Key, value, SOURCE_ENV, o.Env); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for key, value := range flags {
+		if value == "" {
+			continue
+		}
+
+		if err := c.set(key, value, SOURCE_FLAG, "--"+key); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (c *Config) set(key, value, source, origin string) error {
+	if err := validateSetting(key, value); err != nil {
+		return err
+	}
+
+	c.settings[key] = &Setting{Key: key, Value: value, Source: source, Origin: origin}
+
+	return nil
+}
+
+// Value of a setting
+func (c *Config) Get(key string) string {
+	if s, ok := c.settings[key]; ok {
+		return s.Value
+	}
+
+	return ""
+}
+
+// Where a setting's value came from (one of the SOURCE_* constants)
+func (c *Config) Source(key string) string {
+	if s, ok := c.settings[key]; ok {
+		return s.Source
+	}
+
+	return ""
+}
+
+func (c *Config) Bool(key string) bool {
+	value, _ := strconv.ParseBool(c.Get(key))
+	return value
+}
+
+// Value of a comma separated list setting
+func (c *Config) List(key string) []string {
+	list := make([]string, 0)
+
+	for _, item := range strings.Split(c.Get(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+// Print every setting along with where its value came from
+func (c *Config) ListAction(out io.Writer) error {
+	keys := make([]string, 0, len(c.settings))
+
+	for key := range c.settings {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		printSetting(out, c.settings[key])
+	}
+
+	return nil
+}
+
+// Print a single setting along with where its value came from
+func (c *Config) GetAction(out io.Writer, key string) error {
+	s, ok := c.settings[key]
+	if !ok {
+		return fmt.Errorf("Unknown setting '%v'", key)
+	}
+
+	printSetting(out, s)
+
+	return nil
+}
+
+// Store a setting in the config file for scope ("user", "project" or
+// "system"); returns the file written
+func (c *Config) SetAction(key, value, scope string) (string, error) {
+	if err := validateSetting(key, value); err != nil {
+		return "", err
+	}
+
+	var path string
+
+	switch scope {
+	case SOURCE_USER:
+		path = UserConfigPath()
+	case SOURCE_PROJECT:
+		if err := checkProjectSetting(key); err != nil {
+			return "", err
+		}
+
+		// Update the config file in effect, or start one in the current directory
+		if path = ProjectConfigPath(c.workDir); path == "" {
+			path = filepath.Join(c.workDir, PROJECT_CONFIG_FILE)
+		}
+	case SOURCE_SYSTEM:
+		path = SYSTEM_CONFIG_FILE
+	default:
+		return "", fmt.Errorf("Unknown config scope '%v'", scope)
+	}
+
+	if path == "" {
+		return "", fmt.Errorf("Unable to determine the %v config file location", scope)
+	}
+
+	return path, writeConfigValue(path, key, value)
+}
+
+func printSetting(out io.Writer, s *Setting) {
+	source := s.Source
+	if s.Origin != "" {
+		source += " " + s.Origin
+	}
+
+	fmt.Fprintf(out, "%v = %v (%v)\n", s.Key, s.Value, source)
+}
+
+func validateSetting(key, value string) error {
+	for _, o := range Options {
+		if o.Key != key {
+			continue
+		}
+
+		if len(o.Choices) == 0 {
+			return nil
+		}
+
+		for _, choice := range o.Choices {
+			if value == choice {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("Invalid value '%v' for %v (expected one of: %v)", value, key, strings.Join(o.Choices, ", "))
+	}
+
+	return fmt.Errorf("Unknown setting '%v'", key)
+}
+
+// $DKENV_DIR/config.yaml if DKENV_DIR is set, otherwise
+// $XDG_CONFIG_HOME/dkenv/config.yaml (defaulting to ~/.config)
+func UserConfigPath() string {
+	if dir := os.Getenv("DKENV_DIR"); dir != "" {
+		return filepath.Join(dir, CONFIG_FILE)
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(xdgDir("XDG_CONFIG_HOME", home, ".config"), "dkenv", CONFIG_FILE)
+}
+
+// Refuse settings which a project config must not change (see Option.Project)
+func checkProjectSetting(key string) error {
+	o := findOption(key)
+	if o == nil {
+		return fmt.Errorf("Unknown setting '%v'", key)
+	}
+
+	if o.Project {
+		return nil
+	}
+
+	allowed := make([]string, 0)
+
+	for _, o := range Options {
+		if o.Project {
+			allowed = append(allowed, o.Key)
+		}
+	}
+
+	return fmt.Errorf("'%v' can't be set in a project config (%v) - only %v can", key, PROJECT_CONFIG_FILE, strings.Join(allowed, ", "))
+}
+
+func findOption(key string) *Option {
+	for _, o := range Options {
+		if o.Key == key {
+			return o
+		}
+	}
+
+	return nil
+}
+
+// Resolve a relative path from a config file against the file's directory;
+// ~ is left for ExpandPath
+func resolveConfigPath(value, configPath string) string {
+	if value == "" || filepath.IsAbs(value) || value == "~" || strings.HasPrefix(value, "~/") {
+		return value
+	}
+
+	return filepath.Join(filepath.Dir(configPath), value)
+}
+
+// Nearest .dkenv.yaml walking up from dir; empty if there is none
+func ProjectConfigPath(dir string) string {
+	if dir == "" {
+		return ""
+	}
+
+	for current := dir; ; current = filepath.Dir(current) {
+		path := filepath.Join(current, PROJECT_CONFIG_FILE)
+
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+
+		if filepath.Dir(current) == current {
+			return ""
+		}
+	}
+}
+
+// Read a config file. Only the flat subset of YAML dkenv needs is supported:
+// 'key: value' pairs, with lists either as [a, b] or as '- item' lines; list
+// values are returned comma separated.
+func readConfigFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	listKey := ""
+
+	scanner := bufio.NewScanner(f)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+
+		// Item of a block list
+		if strings.HasPrefix(trimmed, "- ") && listKey != "" {
+			item := parseScalar(strings.TrimPrefix(trimmed, "- "))
+
+			if values[listKey] == "" {
+				values[listKey] = item
+			} else {
+				values[listKey] += "," + item
+			}
+
+			continue
+		}
+
+		parts := strings.SplitN(trimmed, ":", 2)
+		if len(parts) != 2 || line != trimmed {
+			return nil, fmt.Errorf("Unable to parse '%v' line %v: expected 'key: value'", path, n)
+		}
+
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+
+		listKey = ""
+
+		switch {
+		case value == "":
+			listKey = key
+			values[key] = ""
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			items := make([]string, 0)
+
+			for _, item := range strings.Split(strings.Trim(value, "[]"), ",") {
+				if item = parseScalar(item); item != "" {
+					items = append(items, item)
+				}
+			}
+
+			values[key] = strings.Join(items, ",")
+		default:
+			values[key] = parseScalar(value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Unable to read '%v': %v", path, err)
+	}
+
+	return values, nil
+}
+
+// Unquote a YAML scalar, dropping trailing comments from unquoted ones
+func parseScalar(value string) string {
+	value = strings.TrimSpace(value)
+
+	if strings.HasPrefix(value, `"`) {
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+	}
+
+	if len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") {
+		return strings.Replace(value[1:len(value)-1], "''", "'", -1)
+	}
+
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+
+	return value
+}
+
+// Set key in a config file, keeping everything else (including comments) as is
+func writeConfigValue(path, key, value string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Unable to read '%v': %v", path, err)
+	}
+
+	newLine := key + ": " + formatScalar(value)
+	if key == "mirrors" {
+		newLine = key + ": [" + strings.Join(formatList(value), ", ") + "]"
+	}
+
+	lines := make([]string, 0)
+	replaced := false
+	inList := false
+
+	if len(data) > 0 {
+		for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+			trimmed := strings.TrimSpace(line)
+
+			// Drop the block list items of the value being replaced
+			if inList && strings.HasPrefix(trimmed, "- ") {
+				continue
+			}
+
+			inList = false
+
+			if line == trimmed && strings.HasPrefix(trimmed, key+":") {
+				lines = append(lines, newLine)
+				replaced = true
+				inList = true
+				continue
+			}
+
+			lines = append(lines, line)
+		}
+	}
+
+	if !replaced {
+		lines = append(lines, newLine)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("Unable to create '%v': %v", filepath.Dir(path), err)
+	}
+
+	tmp := path + ".tmp"
+
+	if err := ioutil.WriteFile(tmp, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("Unable to write '%v': %v", path, err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("Unable to write '%v': %v", path, err)
+	}
+
+	return nil
+}
+
+func formatList(value string) []string {
+	items := make([]string, 0)
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, formatScalar(item))
+		}
+	}
+
+	return items
+}
+
+// Quote a value if YAML would otherwise read it differently
+func formatScalar(value string) string {
+	special := value == "" ||
+		strings.TrimSpace(value) != value ||
+		strings.Contains(value, ": ") ||
+		strings.Contains(value, " #") ||
+		strings.HasSuffix(value, ":") ||
+		strings.ContainsAny(value, ",[]{}") ||
+		strings.ContainsAny(value[:1], "-?#&*!|>'\"%@`")
+
+	if special {
+		return strconv.Quote(value)
+	}
+
+	return value
+}
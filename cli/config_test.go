@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "dkenv_config")
+	if err != nil {
+		t.Fatalf("Unable to create tmp dir for testing: %v", err)
+	}
+	defer cleanUp(tmpDir)
+
+	projectDir := filepath.Join(tmpDir, "project", "sub")
+	os.MkdirAll(projectDir, 0755)
+
+	for _, name := range []string{"DKENV_DIR", "DKENV_BINDIR", "DKENV_PROXY", "XDG_CONFIG_HOME"} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Unsetenv(name)
+	}
+
+	os.Setenv("XDG_CONFIG_HOME", tmpDir)
+
+	userConfig := "# user settings\nbindir: /user/bin\ncachedir: cache\nproxy: 'http://proxy:3128'\nmirrors:\n  - https://a.example\n  - \"https://b.example\"\n"
+	assert.NoError(t, os.MkdirAll(tmpDir+"/dkenv", 0755))
+	assert.NoError(t, ioutil.WriteFile(tmpDir+"/dkenv/"+CONFIG_FILE, []byte(userConfig), 0644))
+	assert.NoError(t, ioutil.WriteFile(tmpDir+"/project/"+PROJECT_CONFIG_FILE, []byte("default_version: 1.9.1 # pinned\n"), 0644))
+
+	c, err := LoadConfig(nil, projectDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "/user/bin", c.Get("bindir"))
+	assert.Equal(t, "1.9.1", c.Get("default_version"))
+	assert.Equal(t, "http://proxy:3128", c.Get("proxy"))
+	assert.Equal(t, []string{"https://a.example", "https://b.example"}, c.List("mirrors"))
+	assert.Equal(t, "", c.Get("dkenvdir"))
+	assert.False(t, c.Bool("debug"))
+
+	// Relative to the config file, not the current directory
+	assert.Equal(t, tmpDir+"/dkenv/cache", c.Get("cachedir"))
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, c.GetAction(out, "default_version"))
+	assert.Equal(t, "default_version = 1.9.1 (project "+tmpDir+"/project/"+PROJECT_CONFIG_FILE+")\n", out.String())
+	assert.Error(t, c.GetAction(out, "nope"))
+
+	// Env beats config files, flags beat env
+	os.Setenv("DKENV_BINDIR", "/env/bin")
+
+	c, err = LoadConfig(nil, projectDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "/env/bin", c.Get("bindir"))
+
+	c, err = LoadConfig(map[string]string{"bindir": "/flag/bin", "dkenvdir": ""}, projectDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "/flag/bin", c.Get("bindir"))
+
+	// Projects can't point dkenv at other binaries or download sources
+	for _, setting := range []string{"dkenvdir: bin", "bindir: /tmp", "mirrors: [https://evil.example]", "channel_url: https://evil.example", "proxy: http://evil:3128", "sudo: true", "link_mode: copy"} {
+		assert.NoError(t, ioutil.WriteFile(tmpDir+"/project/"+PROJECT_CONFIG_FILE, []byte(setting+"\n"), 0644))
+
+		_, err = LoadConfig(nil, projectDir)
+		assert.Error(t, err, setting)
+	}
+
+	// Invalid values are rejected
+	assert.NoError(t, ioutil.WriteFile(tmpDir+"/project/"+PROJECT_CONFIG_FILE, []byte("channel: teleport\n"), 0644))
+
+	_, err = LoadConfig(nil, projectDir)
+	assert.Error(t, err)
+}
+
+func TestSetAction(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "dkenv_config")
+	if err != nil {
+		t.Fatalf("Unable to create tmp dir for testing: %v", err)
+	}
+	defer cleanUp(tmpDir)
+
+	defer os.Setenv("DKENV_DIR", os.Getenv("DKENV_DIR"))
+	os.Setenv("DKENV_DIR", tmpDir)
+
+	path := tmpDir + "/" + CONFIG_FILE
+	assert.NoError(t, ioutil.WriteFile(path, []byte("# keep me\nmirrors:\n  - https://old.example\nproxy: http://p:1\n"), 0644))
+
+	c, err := LoadConfig(nil, tmpDir)
+	assert.NoError(t, err)
+
+	written, err := c.SetAction("mirrors", "https://a.example,https://b.example", "user")
+	assert.NoError(t, err)
+	assert.Equal(t, path, written)
+
+	_, err = c.SetAction("default_version", "1.9.1", "user")
+	assert.NoError(t, err)
+
+	_, err = c.SetAction("nope", "1", "user")
+	assert.Error(t, err)
+
+	_, err = c.SetAction("bindir", "/x", "galaxy")
+	assert.Error(t, err)
+
+	_, err = c.SetAction("dkenvdir", "/x", "project")
+	assert.Error(t, err)
+
+	data, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "# keep me\nmirrors: [https://a.example, https://b.example]\nproxy: http://p:1\ndefault_version: 1.9.1\n", string(data))
+
+	c, err = LoadConfig(nil, tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://a.example", "https://b.example"}, c.List("mirrors"))
+	assert.Equal(t, "1.9.1", c.Get("default_version"))
+}
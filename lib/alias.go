@@ -0,0 +1,255 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	ALIAS_LATEST           = "latest"
+	ALIAS_LATEST_INSTALLED = "latest-installed"
+	ALIAS_LTS              = "lts"
+)
+
+var (
+	aliasRegex = regexp.MustCompile(`^[A-Za-z][0-9A-Za-z._-]*$`)
+
+	// Built-in aliases which need to list the configured channel
+	remoteAliases = map[string]bool{ALIAS_LATEST: true, ALIAS_LTS: true}
+)
+
+// Built-in aliases, resolved on every use
+var builtinAliases = map[string]func(d *Dkenv) (string, error){
+	// Newest release in the configured channel
+	ALIAS_LATEST: func(d *Dkenv) (string, error) {
+		versions, err := d.remoteVersions(d.channel())
+		if err != nil {
+			return "", err
+		}
+
+		latest, ok := latestMatching(versions, "")
+		if !ok {
+			return "", fmt.Errorf("No releases in the %v channel", d.channel())
+		}
+
+		return latest, nil
+	},
+
+	// Newest installed version
+	ALIAS_LATEST_INSTALLED: func(d *Dkenv) (string, error) {
+		installed, err := d.listInstalled()
+		if err != nil {
+			return "", err
+		}
+
+		latest, ok := latestMatching(installed, "")
+		if !ok {
+			return "", fmt.Errorf("No docker versions installed")
+		}
+
+		return latest, nil
+	},
+
+	// Newest stable release of the series before the latest one, eg.
+	// 17.03.2-ce while 17.06 is current; that series still gets fixes while
+	// the newest one settles
+	ALIAS_LTS: func(d *Dkenv) (string, error) {
+		versions, err := d.remoteVersions(CHANNEL_STABLE)
+		if err != nil {
+			return "", err
+		}
+
+		latest, ok := latestMatching(versions, "")
+		if !ok {
+			return "", fmt.Errorf("No releases in the %v channel", CHANNEL_STABLE)
+		}
+
+		lts := ""
+
+		for _, version := range versions {
+			if isPrerelease(version) || releaseSeries(version) == releaseSeries(latest) {
+				continue
+			}
+
+			if lts == "" || compareVersions(version, lts) > 0 {
+				lts = version
+			}
+		}
+
+		if lts == "" {
+			return "", fmt.Errorf("No release older than the %v series in the %v channel", releaseSeries(latest), CHANNEL_STABLE)
+		}
+
+		return lts, nil
+	},
+}
+
+// Major and minor version of a release, eg. "17.06" for "17.06.2-ce"
+func releaseSeries(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+
+	return parts[0] + "." + parts[1]
+}
+
+// Turn an alias (or a version range, see parseRange) into a version; anything
+// that looks like a version is returned as is
+func (d *Dkenv) resolveAlias(name string) (string, error) {
+	if versionRegex.MatchString(name) {
+		return name, nil
+	}
+
+	if isRange(name) {
+		return d.resolveRange(name)
+	}
+
+	if builtin, ok := builtinAliases[name]; ok {
+		version, err := builtin(d)
+		if err != nil {
+			return "", fmt.Errorf("Unable to resolve alias '%v': %v", name, err)
+		}
+
+		log.Infof("Alias '%v' resolves to docker %v", name, version)
+
+		return version, nil
+	}
+
+	state, err := d.loadState()
+	if err != nil {
+		return "", err
+	}
+
+	version, ok := state.Aliases[name]
+	if !ok {
+		return "", fmt.Errorf("Unknown docker version or alias '%v' - see 'dkenv alias'", name)
+	}
+
+	if !versionRegex.MatchString(version) {
+		return "", fmt.Errorf("Alias '%v' points at an invalid docker version '%v'", name, version)
+	}
+
+	log.Infof("Alias '%v' resolves to docker %v", name, version)
+
+	return version, nil
+}
+
+// Show aliases (no name), show one alias (no version) or point an alias at a
+// version; with remove, delete the alias instead
+func (d *Dkenv) AliasAction(name, version string, remove bool) error {
+	switch {
+	case name == "":
+		return d.printAliases()
+	case remove:
+		return d.removeAlias(name)
+	case version == "":
+		resolved, err := d.resolveAlias(name)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(d.Out, resolved)
+
+		return nil
+	}
+
+	if _, ok := builtinAliases[name]; ok {
+		return fmt.Errorf("'%v' is a built-in alias and can't be changed", name)
+	}
+
+	if !aliasRegex.MatchString(name) || versionRegex.MatchString(name) {
+		return fmt.Errorf("Invalid alias '%v' - aliases start with a letter and must not look like versions", name)
+	}
+
+	if !versionRegex.MatchString(version) {
+		return fmt.Errorf("Invalid docker version '%v'", version)
+	}
+
+	err := d.updateState(func(state *State) error {
+		if state.Aliases == nil {
+			state.Aliases = make(map[string]string)
+		}
+
+		state.Aliases[name] = version
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Alias '%v' now points at docker %v", name, version)
+
+	return nil
+}
+
+func (d *Dkenv) removeAlias(name string) error {
+	return d.updateState(func(state *State) error {
+		if _, ok := state.Aliases[name]; !ok {
+			return fmt.Errorf("No such alias '%v'", name)
+		}
+
+		delete(state.Aliases, name)
+		log.Infof("Removed alias '%v'", name)
+
+		return nil
+	})
+}
+
+func (d *Dkenv) printAliases() error {
+	aliases, err := d.aliases(false)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		suffix := ""
+		if _, ok := builtinAliases[name]; ok {
+			suffix = " (built-in)"
+		}
+
+		fmt.Fprintf(d.Out, "%v -> %v%v\n", name, aliases[name], suffix)
+	}
+
+	return nil
+}
+
+// All aliases (built-in ones included, unless they can't be resolved) and
+// the versions they currently point at; with offline, built-ins which need
+// the release listing are left out
+func (d *Dkenv) aliases(offline bool) (map[string]string, error) {
+	state, err := d.loadState()
+	if err != nil {
+		return nil, err
+	}
+
+	aliases := make(map[string]string)
+
+	for name, version := range state.Aliases {
+		aliases[name] = version
+	}
+
+	for name, builtin := range builtinAliases {
+		if offline && remoteAliases[name] {
+			continue
+		}
+
+		if version, err := builtin(d); err == nil {
+			aliases[name] = version
+		}
+	}
+
+	return aliases, nil
+}
@@ -0,0 +1,92 @@
+package lib
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAliasAction(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	var out bytes.Buffer
+
+	d := New(tmpDkenvDir, tmpBinDir)
+	d.Out = &out
+
+	srv := serveChannel(t, d, "17.03.0-ce", "17.03.2-ce", "17.06.0-ce", "17.07.0-ce-rc1")
+	defer srv.Close()
+
+	installFake(t, tmpDkenvDir, "1.8.3")
+	installFake(t, tmpDkenvDir, "1.9.1")
+
+	assert.NoError(t, d.AliasAction("prod", "1.8.3", false))
+
+	version, err := d.resolveAlias("prod")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.8.3", version)
+
+	// Versions pass through
+	version, err = d.resolveAlias("1.7.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.7.1", version)
+
+	// Built-ins; pre-releases are skipped
+	version, _ = d.resolveAlias(ALIAS_LATEST)
+	assert.Equal(t, "17.06.0-ce", version)
+
+	version, _ = d.resolveAlias(ALIAS_LTS)
+	assert.Equal(t, "17.03.2-ce", version)
+
+	version, _ = d.resolveAlias(ALIAS_LATEST_INSTALLED)
+	assert.Equal(t, "1.9.1", version)
+
+	_, err = d.resolveAlias("staging")
+	assert.Error(t, err)
+
+	// Switching by alias
+	assert.NoError(t, d.FetchVersionAction("prod", false))
+	assertLink(t, d.dockerPath(), d.binaryPath("1.8.3"))
+
+	// Shown in list
+	entries, err := d.listEntries(tmpDkenvDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prod"}, entries[0].Aliases)
+	assert.Equal(t, []string{ALIAS_LATEST_INSTALLED}, entries[1].Aliases)
+
+	out.Reset()
+	assert.NoError(t, d.AliasAction("", "", false))
+	assert.Contains(t, out.String(), "prod -> 1.8.3\n")
+	assert.Contains(t, out.String(), "latest -> 17.06.0-ce (built-in)\n")
+	assert.Contains(t, out.String(), "lts -> 17.03.2-ce (built-in)\n")
+
+	// Accepted wherever a version is
+	assert.NoError(t, d.GlobalAction("prod"))
+
+	version, err = readVersionFile(d.globalVersionPath())
+	assert.NoError(t, err)
+	assert.Equal(t, "1.8.3", version)
+
+	assert.NoError(t, d.LocalAction(ALIAS_LATEST_INSTALLED, "", tmpBinDir))
+
+	res, err := d.ResolveVersion(tmpBinDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.9.1", res.Version)
+
+	assert.Error(t, d.LocalAction("staging", "", tmpBinDir))
+	assert.Error(t, d.UninstallAction([]string{"staging"}, false))
+
+	// Invalid or reserved names
+	assert.Error(t, d.AliasAction(ALIAS_LATEST, "1.8.3", false))
+	assert.Error(t, d.AliasAction("1.9", "1.8.3", false))
+	assert.Error(t, d.AliasAction("prod", "not a version", false))
+
+	assert.NoError(t, d.AliasAction("prod", "", true))
+	assert.Error(t, d.AliasAction("prod", "", true))
+
+	_, err = d.resolveAlias("prod")
+	assert.Error(t, err)
+}
@@ -0,0 +1,164 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Environment asdf and mise set for plugin scripts
+const (
+	ASDF_INSTALL_TYPE_ENV    = "ASDF_INSTALL_TYPE"
+	ASDF_INSTALL_VERSION_ENV = "ASDF_INSTALL_VERSION"
+	ASDF_INSTALL_PATH_ENV    = "ASDF_INSTALL_PATH"
+	ASDF_DOWNLOAD_PATH_ENV   = "ASDF_DOWNLOAD_PATH"
+)
+
+// Print every installable version on one line, oldest first (asdf's list-all)
+func (d *Dkenv) AsdfListAllAction() error {
+	versions, err := d.knownVersions()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(d.Out, strings.Join(versions, " "))
+
+	return nil
+}
+
+// Print the newest version starting with query (asdf's latest-stable)
+func (d *Dkenv) AsdfLatestStableAction(query string) error {
+	versions, err := d.knownVersions()
+	if err != nil {
+		return err
+	}
+
+	version, ok := latestMatching(versions, query)
+	if !ok {
+		return fmt.Errorf("No docker version matches '%v'", query)
+	}
+
+	fmt.Fprintln(d.Out, version)
+
+	return nil
+}
+
+// Fetch ASDF_INSTALL_VERSION into the dkenv store and, if asdf asked for it,
+// put a verified copy in ASDF_DOWNLOAD_PATH (asdf's download)
+func (d *Dkenv) AsdfDownloadAction(dir string) error {
+	version, err := d.asdfVersion(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := d.ensureInstalled(version); err != nil {
+		return err
+	}
+
+	downloadPath := os.Getenv(ASDF_DOWNLOAD_PATH_ENV)
+	if downloadPath == "" {
+		return nil
+	}
+
+	return d.copyVerified(version, filepath.Join(downloadPath, "docker"))
+}
+
+// Install ASDF_INSTALL_VERSION as ASDF_INSTALL_PATH/bin/docker (asdf's
+// install); downloads it first if the download step was skipped
+func (d *Dkenv) AsdfInstallAction(dir string) error {
+	installPath := os.Getenv(ASDF_INSTALL_PATH_ENV)
+	if installPath == "" {
+		return fmt.Errorf("%v is not set - this command is meant to be run by asdf or mise", ASDF_INSTALL_PATH_ENV)
+	}
+
+	version, err := d.asdfVersion(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := d.ensureInstalled(version); err != nil {
+		return err
+	}
+
+	dst := filepath.Join(installPath, "bin", "docker")
+
+	if err := d.copyVerified(version, dst); err != nil {
+		return err
+	}
+
+	log.Infof("Installed docker %v to %v", version, dst)
+
+	return nil
+}
+
+// Version asdf wants; falls back to the version resolved for dir when run by
+// hand
+func (d *Dkenv) asdfVersion(dir string) (string, error) {
+	if installType := os.Getenv(ASDF_INSTALL_TYPE_ENV); installType != "" && installType != "version" {
+		return "", fmt.Errorf("Unsupported install type '%v' - dkenv only installs released versions", installType)
+	}
+
+	version := os.Getenv(ASDF_INSTALL_VERSION_ENV)
+
+	if version == "" {
+		res, err := d.ResolveVersion(dir)
+		if err != nil {
+			return "", fmt.Errorf("%v is not set and %v", ASDF_INSTALL_VERSION_ENV, err)
+		}
+
+		version = res.Version
+	}
+
+	if !versionRegex.MatchString(version) {
+		return "", fmt.Errorf("Invalid docker version '%v'", version)
+	}
+
+	return version, nil
+}
+
+// Download a version unless it's installed already
+func (d *Dkenv) ensureInstalled(version string) error {
+	if d.isInstalled(version) {
+		return nil
+	}
+
+	log.Infof("Docker version %v not found - attempting to download...", version)
+
+	return d.DownloadDocker(version)
+}
+
+// Copy an installed version to dst and check the copy against the checksum
+// recorded when it was downloaded
+func (d *Dkenv) copyVerified(version, dst string) error {
+	meta, err := d.loadMeta(version)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("Unable to create '%v': %v", filepath.Dir(dst), err)
+	}
+
+	err = replaceWith(dst, func(tmp string) error {
+		return copyFile(d.binaryPath(version), tmp, 0755)
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to copy docker %v to '%v': %v", version, dst, err)
+	}
+
+	checksum, err := sha256File(dst)
+	if err != nil {
+		return err
+	}
+
+	if checksum != meta.SHA256 {
+		os.Remove(dst)
+		return fmt.Errorf("Docker %v in the store has sha256 %v, but %v was recorded on download - reinstall it with 'dkenv uninstall %v'",
+			version, checksum, meta.SHA256, version)
+	}
+
+	return nil
+}
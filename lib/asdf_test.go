@@ -0,0 +1,113 @@
+package lib
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsdfActions(t *testing.T) {
+	tmpDkenvDir, tmpInstallDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpInstallDir)
+
+	var out bytes.Buffer
+
+	d := New(tmpDkenvDir, tmpInstallDir)
+	d.Out = &out
+
+	srv := serveChannel(t, d, "17.03.0-ce", "17.06.0-ce")
+	defer srv.Close()
+
+	installFake(t, tmpDkenvDir, "1.9.1")
+	installFake(t, tmpDkenvDir, "1.99.0")
+
+	assert.NoError(t, d.AsdfListAllAction())
+	assert.Contains(t, out.String(), "1.0.0 1.0.1 ")
+	assert.Contains(t, out.String(), " 1.10.3 1.99.0 17.03.0-ce 17.06.0-ce\n")
+
+	out.Reset()
+	assert.NoError(t, d.AsdfLatestStableAction(""))
+	assert.Equal(t, "17.06.0-ce\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, d.AsdfLatestStableAction("1.9"))
+	assert.Equal(t, "1.9.1\n", out.String())
+
+	assert.Error(t, d.AsdfLatestStableAction("2"))
+
+	// Must be run by asdf
+	assert.Error(t, d.AsdfInstallAction(tmpInstallDir))
+
+	os.Setenv(ASDF_INSTALL_VERSION_ENV, "1.9.1")
+	os.Setenv(ASDF_INSTALL_PATH_ENV, tmpInstallDir)
+	os.Setenv(ASDF_DOWNLOAD_PATH_ENV, filepath.Join(tmpInstallDir, "download"))
+	defer os.Unsetenv(ASDF_INSTALL_VERSION_ENV)
+	defer os.Unsetenv(ASDF_INSTALL_PATH_ENV)
+	defer os.Unsetenv(ASDF_DOWNLOAD_PATH_ENV)
+
+	assert.NoError(t, d.AsdfDownloadAction(tmpInstallDir))
+	assert.NoError(t, d.AsdfInstallAction(tmpInstallDir))
+
+	for _, path := range []string{"download/docker", "bin/docker"} {
+		data, err := ioutil.ReadFile(filepath.Join(tmpInstallDir, path))
+		assert.NoError(t, err)
+		assert.Equal(t, "binary", string(data))
+	}
+
+	// Store binary no longer matches its metadata
+	if err := ioutil.WriteFile(d.binaryPath("1.9.1"), []byte("tampered"), 0755); err != nil {
+		t.Fatalf("Unable to modify fake docker binary: %v", err)
+	}
+
+	assert.Error(t, d.AsdfInstallAction(tmpInstallDir))
+
+	os.Setenv(ASDF_INSTALL_TYPE_ENV, "ref")
+	defer os.Unsetenv(ASDF_INSTALL_TYPE_ENV)
+
+	assert.Error(t, d.AsdfInstallAction(tmpInstallDir))
+}
+
+func TestAsdfListAllWithoutChannels(t *testing.T) {
+	tmpDkenvDir, tmpInstallDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpInstallDir)
+
+	var out bytes.Buffer
+
+	d := New(tmpDkenvDir, tmpInstallDir)
+	d.Out = &out
+
+	// Eg. Windows, where only the plain binaries on the mirrors exist
+	defer func(goos string) { channelOS = goos }(channelOS)
+	channelOS = "windows"
+
+	installFake(t, tmpDkenvDir, "1.99.0")
+
+	assert.NoError(t, d.AsdfListAllAction())
+	assert.True(t, strings.HasPrefix(out.String(), "1.0.0 1.0.1 "))
+	assert.True(t, strings.HasSuffix(out.String(), " 1.10.3 1.99.0\n"))
+
+	out.Reset()
+	assert.NoError(t, d.AsdfLatestStableAction("1.9"))
+	assert.Equal(t, "1.9.1\n", out.String())
+}
+
+func TestLatestMatching(t *testing.T) {
+	versions := []string{"1.9.0", "1.9.1", "1.10.3", "1.90.0"}
+
+	latest, ok := latestMatching(append(versions, "1.9.2-rc1"), "1.9")
+	assert.True(t, ok)
+	assert.Equal(t, "1.9.1", latest)
+
+	latest, _ = latestMatching(versions, "")
+	assert.Equal(t, "1.90.0", latest)
+
+	_, ok = latestMatching(versions, "1.11")
+	assert.False(t, ok)
+}
@@ -0,0 +1,217 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	BACKUP_ID_FORMAT = "20060102-150405"
+)
+
+// Move aside a (non-dkenv) docker binary by hardlinking (or, where that's not
+// possible, copying) it to a predictable, unique name and recording it in the
+// dkenv state
+func (d *Dkenv) backupFile(dst string) (*Backup, error) {
+	checksum, err := sha256File(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	backup := &Backup{
+		OriginalPath: dst,
+		SHA256:       checksum,
+		CreatedAt:    now,
+	}
+
+	err = d.updateState(func(state *State) error {
+		// Pick an ID whose backup file doesn't exist yet
+		id := now.Format(BACKUP_ID_FORMAT)
+
+		for i := 1; ; i++ {
+			backup.ID = id
+			backup.Path = fmt.Sprintf("%v.%v.dkenv", dst, id)
+
+			if _, err := os.Lstat(backup.Path); os.IsNotExist(err) && state.findBackup(id) == nil {
+				break
+			}
+
+			id = fmt.Sprintf("%v-%v", now.Format(BACKUP_ID_FORMAT), i)
+		}
+
+		log.Infof("Backing up existing docker file %v to %v", dst, backup.Path)
+
+		// Hardlink or copy rather than rename, so dst keeps existing until
+		// the new symlink is renamed over it
+		if err := d.preserveFile(dst, backup.Path); err != nil {
+			return fmt.Errorf("Unable to backup existing docker file: %v", err)
+		}
+
+		state.Backups = append(state.Backups, backup)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return backup, nil
+}
+
+// Make path a hardlink (or copy) of src; with --sudo, copies through sudo if
+// path's directory isn't writable
+func (d *Dkenv) preserveFile(src, path string) error {
+	err := linkOrCopy(src, path)
+	if err != nil && os.IsPermission(err) && d.Sudo {
+		log.Infof("Using sudo to copy '%v' to '%v'", src, path)
+		err = sudo("cp", "-p", src, path)
+	}
+
+	return err
+}
+
+// List backups; optionally prune them down to the newest 'keep' backups
+func (d *Dkenv) BackupsAction(prune bool, keep int) error {
+	if prune {
+		return d.pruneBackups(keep)
+	}
+
+	state, err := d.loadState()
+	if err != nil {
+		return err
+	}
+
+	if len(state.Backups) == 0 {
+		log.Warning("No docker backups found!")
+		return nil
+	}
+
+	log.Infof("Found %v docker backups", len(state.Backups))
+	log.Info("") // blank line, for the pretty
+
+	for i, b := range state.Backups {
+		log.Infof("%v: %v - %v (backup of %v, taken %v) [%v]", i+1, b.ID, b.Path, b.OriginalPath,
+			b.CreatedAt.Format(time.RFC3339), b.status())
+	}
+
+	return nil
+}
+
+// Drop records of vanished backups, and remove all but the newest 'keep'
+// backups
+func (d *Dkenv) pruneBackups(keep int) error {
+	return d.updateState(func(state *State) error {
+		remaining := make([]*Backup, 0)
+
+		for _, b := range state.Backups {
+			if b.status() == "missing" {
+				log.Infof("Forgetting backup %v - %v no longer exists", b.ID, b.Path)
+				continue
+			}
+
+			remaining = append(remaining, b)
+		}
+
+		// Backups are appended in chronological order; the newest are last
+		for len(remaining) > keep {
+			b := remaining[0]
+
+			log.Infof("Removing backup %v (%v)", b.ID, b.Path)
+
+			if err := os.Remove(b.Path); err != nil {
+				return fmt.Errorf("Unable to remove backup '%v': %v", b.Path, err)
+			}
+
+			remaining = remaining[1:]
+		}
+
+		state.Backups = remaining
+
+		return nil
+	})
+}
+
+// Put a backed up docker binary back in place. Restores the most recent
+// backup if id is empty.
+func (d *Dkenv) RestoreAction(id string, force bool) error {
+	l, err := d.lock("link")
+	if err != nil {
+		return err
+	}
+	defer l.unlock()
+
+	return d.updateState(func(state *State) error {
+		if len(state.Backups) == 0 {
+			return fmt.Errorf("No docker backups found")
+		}
+
+		b := state.Backups[len(state.Backups)-1]
+
+		if id != "" {
+			if b = state.findBackup(id); b == nil {
+				return fmt.Errorf("No such backup '%v'", id)
+			}
+		}
+
+		switch status := b.status(); {
+		case status == "missing":
+			return fmt.Errorf("Backup file '%v' no longer exists", b.Path)
+		case status != "ok" && !force:
+			return fmt.Errorf("Backup file '%v' has been modified since it was taken (use --force to restore anyway)", b.Path)
+		}
+
+		log.Infof("Restoring %v to %v", b.Path, b.OriginalPath)
+
+		if err := os.Rename(b.Path, b.OriginalPath); err != nil {
+			return fmt.Errorf("Unable to restore backup: %v", err)
+		}
+
+		state.removeBackup(b.ID)
+
+		if b.OriginalPath == d.dockerPath() {
+			state.ActiveLink = ""
+		}
+
+		return nil
+	})
+}
+
+func (s *State) findBackup(id string) *Backup {
+	for _, b := range s.Backups {
+		if b.ID == id {
+			return b
+		}
+	}
+
+	return nil
+}
+
+func (s *State) removeBackup(id string) {
+	for i, b := range s.Backups {
+		if b.ID == id {
+			s.Backups = append(s.Backups[:i], s.Backups[i+1:]...)
+			return
+		}
+	}
+}
+
+// Report whether the backup file is still around and unchanged
+func (b *Backup) status() string {
+	checksum, err := sha256File(b.Path)
+
+	switch {
+	case err != nil && os.IsNotExist(err):
+		return "missing"
+	case err != nil:
+		return "unreadable"
+	case checksum != b.SHA256:
+		return "modified"
+	}
+
+	return "ok"
+}
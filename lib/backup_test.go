@@ -0,0 +1,122 @@
+package lib
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+	dst := tmpBinDir + "/docker"
+
+	installFake(t, tmpDkenvDir, "1.9.1")
+
+	if err := ioutil.WriteFile(dst, []byte("original"), 0755); err != nil {
+		t.Fatalf("Unable to create fake docker binary: %v", err)
+	}
+
+	// Nothing to restore yet
+	assert.Error(t, d.RestoreAction("", false))
+
+	assert.NoError(t, d.UpdateSymlink("1.9.1"))
+
+	state, err := d.loadState()
+	assert.NoError(t, err)
+	assert.Len(t, state.Backups, 1)
+
+	backup := state.Backups[0]
+	assert.Equal(t, dst, backup.OriginalPath)
+	assert.Equal(t, "ok", backup.status())
+	assert.NoError(t, d.BackupsAction(false, 0))
+
+	// Unknown backup
+	assert.Error(t, d.RestoreAction("nope", false))
+
+	// Restore the original binary over the symlink
+	assert.NoError(t, d.RestoreAction(backup.ID, false))
+
+	data, err := ioutil.ReadFile(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, "original", string(data))
+
+	state, err = d.loadState()
+	assert.NoError(t, err)
+	assert.Len(t, state.Backups, 0)
+}
+
+func TestPruneBackups(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+	dst := tmpBinDir + "/docker"
+
+	if err := ioutil.WriteFile(dst, []byte("original"), 0755); err != nil {
+		t.Fatalf("Unable to create fake docker binary: %v", err)
+	}
+
+	// Several backups taken within the same second must not collide
+	var backups []*Backup
+
+	for i := 0; i < 3; i++ {
+		b, err := d.backupFile(dst)
+		assert.NoError(t, err)
+		backups = append(backups, b)
+	}
+
+	assert.NotEqual(t, backups[0].Path, backups[1].Path)
+	assert.NotEqual(t, backups[1].Path, backups[2].Path)
+
+	// A vanished backup is forgotten, and only the newest one is kept
+	os.Remove(backups[2].Path)
+	assert.NoError(t, d.BackupsAction(true, 1))
+
+	state, err := d.loadState()
+	assert.NoError(t, err)
+	assert.Len(t, state.Backups, 1)
+	assert.Equal(t, backups[1].ID, state.Backups[0].ID)
+
+	_, err = os.Stat(backups[0].Path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestBackupWithoutHardlinks(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	linkFile = func(oldname, newname string) error {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: errors.New("operation not permitted")}
+	}
+	defer func() { linkFile = os.Link }()
+
+	d := New(tmpDkenvDir, tmpBinDir)
+	dst := tmpBinDir + "/docker"
+
+	installFake(t, tmpDkenvDir, "1.9.1")
+
+	if err := ioutil.WriteFile(dst, []byte("original"), 0755); err != nil {
+		t.Fatalf("Unable to create fake docker binary: %v", err)
+	}
+
+	// The backup is copied instead, and the switch goes through
+	assert.NoError(t, d.UpdateSymlink("1.9.1"))
+
+	state, err := d.loadState()
+	assert.NoError(t, err)
+	assert.Len(t, state.Backups, 1)
+	assert.Equal(t, "ok", state.Backups[0].status())
+
+	info, err := os.Stat(state.Backups[0].Path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+}
@@ -0,0 +1,309 @@
+package lib
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	CHANNEL_STABLE  = "stable"
+	CHANNEL_EDGE    = "edge"
+	CHANNEL_TEST    = "test"
+	CHANNEL_NIGHTLY = "nightly"
+
+	DEFAULT_CHANNEL_URL = "https://download.docker.com"
+
+	// First release published per channel (17.03.0-ce)
+	FIRST_CHANNEL_MAJOR = 17
+)
+
+var (
+	Channels = []string{CHANNEL_STABLE, CHANNEL_EDGE, CHANNEL_TEST, CHANNEL_NIGHTLY}
+
+	// Links in a channel's directory listing
+	channelIndexRegex = regexp.MustCompile(`href="docker-([0-9][^"]*)\.tgz"`)
+
+	// Replaced in tests to simulate systems without channel downloads
+	channelOS = runtime.GOOS
+
+	// Directory names download.docker.com uses for GOARCH values
+	channelArchs = map[string]string{
+		"amd64":   "x86_64",
+		"arm64":   "aarch64",
+		"arm":     "armhf",
+		"s390x":   "s390x",
+		"ppc64le": "ppc64le",
+	}
+)
+
+// Report whether a version is published per channel, rather than as a plain
+// binary under the mirrors
+func isChannelVersion(version string) bool {
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+
+	return err == nil && major >= FIRST_CHANNEL_MAJOR
+}
+
+// Channel to download from; defaults to stable
+func (d *Dkenv) channel() string {
+	if d.Channel == "" {
+		return CHANNEL_STABLE
+	}
+
+	return d.Channel
+}
+
+// Directory names of the current system and architecture in the channels,
+// eg. "linux" and "x86_64"
+func channelPlatform() (string, string, error) {
+	var system string
+
+	switch channelOS {
+	case "linux":
+		system = "linux"
+	case "darwin":
+		system = "mac"
+	default:
+		return "", "", fmt.Errorf("Channel downloads are not supported on %v", channelOS)
+	}
+
+	arch, ok := channelArchs[runtime.GOARCH]
+	if !ok {
+		return "", "", fmt.Errorf("Channel downloads are not supported on %v", runtime.GOARCH)
+	}
+
+	return system, arch, nil
+}
+
+// Base URL of a channel for the current system, eg.
+// https://download.docker.com/linux/static/stable/x86_64
+func (d *Dkenv) channelDir(channel string) (string, error) {
+	system, arch, err := channelPlatform()
+	if err != nil {
+		return "", err
+	}
+
+	base := d.ChannelURL
+	if base == "" {
+		base = DEFAULT_CHANNEL_URL
+	}
+
+	return strings.TrimRight(base, "/") + "/" + system + "/static/" + channel + "/" + arch, nil
+}
+
+// Fetch the release archive of a version from a channel and return the docker
+// client binary in it, along with the URL it came from
+func (d *Dkenv) downloadChannel(version, channel string) ([]byte, string, error) {
+	dir, err := d.channelDir(channel)
+	if err != nil {
+		return nil, "", err
+	}
+
+	client, err := d.httpClient()
+	if err != nil {
+		return nil, "", err
+	}
+
+	url := dir + "/docker-" + version + ".tgz"
+
+	log.Debugf("Downloading %v", url)
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, "", fmt.Errorf("Unable to download docker %v: %v", version, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return nil, "", fmt.Errorf("No docker version '%v' in the %v channel (%v returned %v) - %v", version, channel, url, resp.Status,
+			channelHint(version, channel))
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, "", fmt.Errorf("Unable to download docker %v: %v returned %v", version, url, resp.Status)
+	}
+
+	readerpt := &PassThru{Reader: resp.Body, length: resp.ContentLength}
+
+	archive, err := ioutil.ReadAll(readerpt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	body, err := extractClient(archive)
+	if err != nil {
+		return nil, "", fmt.Errorf("Unable to extract docker %v from %v: %v", version, url, err)
+	}
+
+	return body, url, nil
+}
+
+// Suggest where to look for a version missing from a channel
+func channelHint(version, channel string) string {
+	if isPrerelease(version) && channel != CHANNEL_TEST {
+		return fmt.Sprintf("pre-releases are published in the %v channel, try --channel %v", CHANNEL_TEST, CHANNEL_TEST)
+	}
+
+	others := make([]string, 0, len(Channels))
+
+	for _, c := range Channels {
+		if c != channel {
+			others = append(others, c)
+		}
+	}
+
+	return fmt.Sprintf("it may be in a different channel (--channel %v), see 'dkenv list-remote'", strings.Join(others, ", "))
+}
+
+// Return docker/docker from a release .tgz
+func extractClient(archive []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if path.Clean(hdr.Name) == "docker/docker" && hdr.Typeflag == tar.TypeReg {
+			return ioutil.ReadAll(tr)
+		}
+	}
+
+	return nil, fmt.Errorf("No docker/docker in archive")
+}
+
+// Versions available in a channel, oldest first; the stable channel includes
+// the legacy releases
+func (d *Dkenv) remoteVersions(channel string) ([]string, error) {
+	dir, err := d.channelDir(channel)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := d.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Get(dir + "/")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list the %v channel: %v", channel, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Unable to list the %v channel: %v returned %v", channel, dir, resp.Status)
+	}
+
+	index, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := parseChannelIndex(index)
+
+	if channel == CHANNEL_STABLE {
+		versions = append(append([]string{}, legacyReleases...), versions...)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return compareVersions(versions[i], versions[j]) < 0
+	})
+
+	return versions, nil
+}
+
+// Versions linked from a channel's directory listing
+func parseChannelIndex(index []byte) []string {
+	seen := make(map[string]bool)
+	versions := make([]string, 0)
+
+	for _, match := range channelIndexRegex.FindAllSubmatch(index, -1) {
+		version := string(match[1])
+
+		if !seen[version] && versionRegex.MatchString(version) {
+			seen[version] = true
+			versions = append(versions, version)
+		}
+	}
+
+	return versions
+}
+
+// Print the versions in a channel, optionally limited to a range (see
+// parseRange); pre-releases are left out unless pre is set
+func (d *Dkenv) ListRemoteAction(channel, rangeExpr string, pre bool) error {
+	if channel == "" {
+		channel = d.channel()
+	}
+
+	var constraints []*versionConstraint
+
+	if rangeExpr != "" {
+		var err error
+
+		if constraints, err = parseRange(rangeExpr); err != nil {
+			return err
+		}
+	}
+
+	versions, err := d.remoteVersions(channel)
+	if err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		if !matchesRange(version, constraints, pre) {
+			continue
+		}
+
+		fmt.Fprintln(d.Out, version)
+	}
+
+	return nil
+}
+
+// Newest version in the configured channel matching a range
+func (d *Dkenv) resolveRange(rangeExpr string) (string, error) {
+	constraints, err := parseRange(rangeExpr)
+	if err != nil {
+		return "", err
+	}
+
+	versions, err := d.remoteVersions(d.channel())
+	if err != nil {
+		return "", err
+	}
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		if matchesRange(versions[i], constraints, false) {
+			log.Infof("Range '%v' resolves to docker %v", rangeExpr, versions[i])
+			return versions[i], nil
+		}
+	}
+
+	return "", fmt.Errorf("No docker version in the %v channel matches '%v'", d.channel(), rangeExpr)
+}
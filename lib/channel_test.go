@@ -0,0 +1,115 @@
+package lib
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsChannelVersion(t *testing.T) {
+	assert.True(t, isChannelVersion("17.03.0-ce"))
+	assert.True(t, isChannelVersion("20.10.7"))
+	assert.False(t, isChannelVersion("1.13.1"))
+	assert.False(t, isChannelVersion("garbage"))
+}
+
+func TestParseChannelIndex(t *testing.T) {
+	index := []byte(`<html><body>
+<a href="../">../</a>
+<a href="docker-17.06.0-ce.tgz">docker-17.06.0-ce.tgz</a>
+<a href="docker-17.06.0-ce-rc1.tgz">docker-17.06.0-ce-rc1.tgz</a>
+<a href="docker-rootless-extras-20.10.0.tgz">docker-rootless-extras-20.10.0.tgz</a>
+<a href="docker-17.06.0-ce.tgz">docker-17.06.0-ce.tgz</a>
+</body></html>`)
+
+	assert.Equal(t, []string{"17.06.0-ce", "17.06.0-ce-rc1"}, parseChannelIndex(index))
+}
+
+func TestExtractClient(t *testing.T) {
+	body, err := extractClient(releaseArchive("client"))
+	assert.NoError(t, err)
+	assert.Equal(t, "client", string(body))
+
+	_, err = extractClient([]byte("not an archive"))
+	assert.Error(t, err)
+}
+
+func TestDownloadFromWrongChannel(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+
+	srv := serveChannel(t, d)
+	defer srv.Close()
+
+	err := d.DownloadDocker("17.06.0-ce-rc1")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "--channel test")
+
+	err = d.DownloadDocker("17.06.0-ce")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "different channel")
+}
+
+func TestChannelDir(t *testing.T) {
+	d := New("", "")
+	d.ChannelURL = "https://mirror.example/docker/"
+
+	dir, err := d.channelDir(CHANNEL_EDGE)
+	if err != nil {
+		t.Skipf("Channel downloads not supported here: %v", err)
+	}
+
+	assert.Contains(t, dir, "https://mirror.example/docker/")
+	assert.Contains(t, dir, "/static/edge/")
+}
+
+// Serve a channel directory listing with the given versions (but no
+// archives) and point d.ChannelURL at it
+func serveChannel(t *testing.T, d *Dkenv, versions ...string) *httptest.Server {
+	if _, err := d.channelDir(CHANNEL_STABLE); err != nil {
+		t.Skipf("Channel downloads not supported here: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		for _, version := range versions {
+			fmt.Fprintf(w, "<a href=\"docker-%v.tgz\">docker-%v.tgz</a>\n", version, version)
+		}
+	}))
+
+	d.ChannelURL = srv.URL
+
+	return srv
+}
+
+// A release .tgz as published in the channels, with the given client binary
+func releaseArchive(client string) []byte {
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, content := range map[string]string{"docker/dockerd": "daemon", "docker/docker": client} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+
+	tw.Close()
+	gz.Close()
+
+	return buf.Bytes()
+}
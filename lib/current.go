@@ -0,0 +1,164 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// The docker binary which would run, and how it was chosen
+type Active struct {
+	Resolution
+
+	Path string // Absolute path to the docker binary
+}
+
+// Figure out which docker binary would run in dir: a shell session (see
+// 'dkenv shell') beats the BinDir shim, which resolves via env, local or
+// global; otherwise it's whatever the BinDir symlink points at
+func (d *Dkenv) ActiveVersion(dir string) (*Active, error) {
+	if sessionDir := os.Getenv(SESSION_ENV); sessionDir != "" {
+		link := sessionDir + "/docker"
+
+		if target, err := os.Readlink(link); err == nil {
+			if version, ok := d.versionFromPath(target); ok {
+				reason := fmt.Sprintf("%v is set and %v points at %v", SESSION_ENV, link, target)
+
+				return d.newActive(version, "session", link, reason), nil
+			}
+		}
+	}
+
+	dst := d.dockerPath()
+
+	info, err := d.inspectLink(dst)
+	if err != nil && os.IsNotExist(err) {
+		return nil, fmt.Errorf("No docker found in %v - use 'dkenv client' to install one", d.BinDir)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to lookup link info for '%v': %v", dst, err)
+	}
+
+	if info.Mode == "" && info.Target == "" {
+		return nil, fmt.Errorf("'%v' is not managed by dkenv (not a symlink, hardlink or copy of an installed version)", dst)
+	}
+
+	if info.Mode == LINK_SHIM {
+		res, err := d.ResolveVersion(dir)
+		if err == errNoVersion {
+			return nil, fmt.Errorf("Docker shim installed but %v - use 'dkenv local' or 'dkenv global' to set one", err)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &Active{Resolution: *res, Path: d.binaryPath(res.Version)}, nil
+	}
+
+	if info.Mode == "" {
+		return nil, fmt.Errorf("'%v' is not managed by dkenv (points at %v)", dst, info.Target)
+	}
+
+	reason := fmt.Sprintf("%v points at %v", dst, info.Target)
+
+	if info.Target == "" {
+		reason = fmt.Sprintf("%v is a %v", dst, info)
+	}
+
+	return d.newActive(info.Version, info.Mode, dst, reason), nil
+}
+
+func (d *Dkenv) newActive(version, source, origin, reason string) *Active {
+	return &Active{
+		Resolution: Resolution{Version: version, Source: source, Origin: origin, Reason: reason},
+		Path:       d.binaryPath(version),
+	}
+}
+
+// Report the docker version which would run in dir, its API version and how
+// it was chosen
+func (d *Dkenv) CurrentAction(dir string) error {
+	active, err := d.ActiveVersion(dir)
+	if err != nil {
+		return err
+	}
+
+	api, err := VersionToApi(active.Version)
+	if err != nil {
+		api = "unknown"
+	}
+
+	fmt.Fprintf(d.Out, "%v (API %v) - set by %v (%v)\n", active.Version, api, active.Origin, active.Source)
+	log.Infof("Using %v version: %v", active.Source, active.Reason)
+
+	d.warnInconsistencies(dir, active)
+
+	return nil
+}
+
+// Print the absolute path of the docker binary which would run in dir
+func (d *Dkenv) WhichAction(dir string) error {
+	active, err := d.ActiveVersion(dir)
+	if err != nil {
+		return err
+	}
+
+	path, err := filepath.Abs(active.Path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("Docker version %v is not installed (%v)", active.Version, path)
+	}
+
+	fmt.Fprintln(d.Out, path)
+
+	d.warnInconsistencies(dir, active)
+
+	return nil
+}
+
+// Warn about things which make the active version differ from what the user
+// probably expects
+func (d *Dkenv) warnInconsistencies(dir string, active *Active) {
+	if !d.isInstalled(active.Version) {
+		log.Warningf("Docker version %v is not installed - use 'dkenv client %v' to install it", active.Version, active.Version)
+	}
+
+	if changed, current, expected := d.linkChanged(); changed {
+		log.Warningf("%v was changed outside of dkenv (points at '%v', dkenv last set '%v')", d.dockerPath(), current, expected)
+	}
+
+	if !isLinkMode(active.Source) || active.Source == LINK_SHIM {
+		return
+	}
+
+	// A .docker-version/global only takes effect via the shim or a session
+	if res, err := d.ResolveVersion(dir); err == nil && res.Version != active.Version {
+		log.Warningf("%v requests docker %v but %v is active - see 'dkenv shim' and 'dkenv hook'", res.Origin, res.Version, active.Version)
+	}
+}
+
+// Check whether BinDir/docker still points where dkenv last put it
+func (d *Dkenv) linkChanged() (bool, string, string) {
+	state, err := d.loadState()
+	if err != nil || state.ActiveLink == "" {
+		return false, "", ""
+	}
+
+	info, err := d.inspectLink(d.dockerPath())
+	if err != nil {
+		return true, "<missing>", state.ActiveLink
+	}
+
+	if info.Source == "" {
+		return true, info.String(), state.ActiveLink
+	}
+
+	return info.Source != state.ActiveLink, info.String(), state.ActiveLink
+}
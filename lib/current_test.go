@@ -0,0 +1,89 @@
+package lib
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActiveVersion(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	os.Unsetenv(SESSION_ENV)
+	os.Unsetenv(VERSION_ENV)
+
+	for _, version := range []string{"1.8.3", "1.9.1"} {
+		installFake(t, tmpDkenvDir, version)
+	}
+
+	out := &bytes.Buffer{}
+
+	d := New(tmpDkenvDir, tmpBinDir)
+	d.Out = out
+
+	// Nothing installed
+	_, err := d.ActiveVersion(tmpBinDir)
+	assert.Error(t, err)
+
+	// Plain symlink
+	assert.NoError(t, d.UpdateSymlink("1.9.1"))
+
+	active, err := d.ActiveVersion(tmpBinDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.9.1", active.Version)
+	assert.Equal(t, "symlink", active.Source)
+	assert.Equal(t, d.binaryPath("1.9.1"), active.Path)
+
+	assert.NoError(t, d.CurrentAction(tmpBinDir))
+	assert.Equal(t, "1.9.1 (API 1.21) - set by "+d.dockerPath()+" (symlink)\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, d.WhichAction(tmpBinDir))
+	assert.Equal(t, d.binaryPath("1.9.1"), strings.TrimSpace(out.String()))
+
+	// Changed behind dkenv's back
+	changed, _, _ := d.linkChanged()
+	assert.False(t, changed)
+
+	assert.NoError(t, replaceSymlink(d.binaryPath("1.8.3"), d.dockerPath()))
+
+	changed, current, expected := d.linkChanged()
+	assert.True(t, changed)
+	assert.Equal(t, d.binaryPath("1.8.3"), current)
+	assert.Equal(t, d.binaryPath("1.9.1"), expected)
+
+	// Shim resolves via .docker-version
+	assert.NoError(t, d.InstallShimAction())
+	assert.NoError(t, d.LocalAction("1.8.3", "", tmpBinDir))
+
+	active, err = d.ActiveVersion(tmpBinDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.8.3", active.Version)
+	assert.Equal(t, "local", active.Source)
+
+	// Session beats everything
+	assert.NoError(t, os.MkdirAll(tmpDkenvDir+"/sessions/x", 0700))
+	assert.NoError(t, os.Symlink(d.binaryPath("1.9.1"), tmpDkenvDir+"/sessions/x/docker"))
+
+	os.Setenv(SESSION_ENV, tmpDkenvDir+"/sessions/x")
+	defer os.Unsetenv(SESSION_ENV)
+
+	active, err = d.ActiveVersion(tmpBinDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.9.1", active.Version)
+	assert.Equal(t, "session", active.Source)
+
+	// Unmanaged docker
+	os.Unsetenv(SESSION_ENV)
+	os.Remove(d.dockerPath())
+	assert.NoError(t, ioutil.WriteFile(d.dockerPath(), []byte("other"), 0755))
+
+	_, err = d.ActiveVersion(tmpBinDir)
+	assert.Error(t, err)
+}
@@ -0,0 +1,131 @@
+package lib
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+const (
+	DEFAULT_DOCKER_HOST = "unix:///var/run/docker.sock"
+	DAEMON_TIMEOUT      = 5 * time.Second
+)
+
+// Subset of the daemon's /version response that we care about
+type daemonVersion struct {
+	Version    string
+	ApiVersion string
+}
+
+// Ask the docker daemon (as configured via DOCKER_HOST & friends) which API
+// version it speaks
+func DaemonApiVersion() (string, error) {
+	client, baseURL, err := daemonClient()
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Get(baseURL + "/version")
+	if err != nil {
+		return "", fmt.Errorf("Unable to reach docker daemon: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("Unexpected response from docker daemon: %v", resp.Status)
+	}
+
+	var v daemonVersion
+
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return "", fmt.Errorf("Unable to decode docker daemon version: %v", err)
+	}
+
+	if v.ApiVersion == "" {
+		return "", fmt.Errorf("Docker daemon did not report an API version")
+	}
+
+	return v.ApiVersion, nil
+}
+
+// Build an http client + base URL for talking to the daemon
+func daemonClient() (*http.Client, string, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = DEFAULT_DOCKER_HOST
+	}
+
+	parts := strings.SplitN(host, "://", 2)
+	if len(parts) != 2 {
+		return nil, "", fmt.Errorf("Unable to parse DOCKER_HOST '%v'", host)
+	}
+
+	transport := &http.Transport{}
+
+	switch parts[0] {
+	case "unix":
+		socket := parts[1]
+
+		transport.Dial = func(_, _ string) (net.Conn, error) {
+			return net.DialTimeout("unix", socket, DAEMON_TIMEOUT)
+		}
+
+		return &http.Client{Transport: transport, Timeout: DAEMON_TIMEOUT}, "http://docker", nil
+	case "tcp":
+		scheme := "http"
+
+		if os.Getenv("DOCKER_TLS_VERIFY") != "" {
+			tlsConfig, err := daemonTLSConfig()
+			if err != nil {
+				return nil, "", err
+			}
+
+			transport.TLSClientConfig = tlsConfig
+			scheme = "https"
+		}
+
+		return &http.Client{Transport: transport, Timeout: DAEMON_TIMEOUT}, scheme + "://" + parts[1], nil
+	}
+
+	return nil, "", fmt.Errorf("Unsupported DOCKER_HOST protocol '%v'", parts[0])
+}
+
+func daemonTLSConfig() (*tls.Config, error) {
+	certPath := os.Getenv("DOCKER_CERT_PATH")
+	if certPath == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to fetch current home dir: %v", err)
+		}
+
+		certPath = filepath.Join(home, ".docker")
+	}
+
+	cert, err := tls.LoadX509KeyPair(filepath.Join(certPath, "cert.pem"), filepath.Join(certPath, "key.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load docker client certificate: %v", err)
+	}
+
+	ca, err := ioutil.ReadFile(filepath.Join(certPath, "ca.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load docker CA certificate: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca)
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+	}, nil
+}
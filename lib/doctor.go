@@ -0,0 +1,293 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	CHECK_PASS = "pass"
+	CHECK_WARN = "warn"
+	CHECK_FAIL = "fail"
+)
+
+// Result of a single doctor check
+type Check struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Fix     string `json:"fix,omitempty"`
+}
+
+// Check everything dkenv relies on; prints pass/warn/fail lines (or JSON) and
+// returns an error if any check failed
+func (d *Dkenv) DoctorAction(asJSON bool) error {
+	checks := make([]*Check, 0)
+
+	checks = append(checks, d.checkBinDir()...)
+	checks = append(checks, d.checkDkenvDir()...)
+	checks = append(checks, d.checkDockerLink()...)
+	checks = append(checks, d.checkPath()...)
+	checks = append(checks, d.checkInstalled()...)
+	checks = append(checks, d.checkUnmanagedFiles()...)
+	checks = append(checks, d.checkDaemon()...)
+
+	if asJSON {
+		data, err := json.MarshalIndent(checks, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(d.Out, string(data))
+	} else {
+		for _, c := range checks {
+			fmt.Fprintf(d.Out, "[%v] %v: %v\n", strings.ToUpper(c.Status), c.Name, c.Message)
+
+			if c.Fix != "" {
+				fmt.Fprintf(d.Out, "       fix: %v\n", c.Fix)
+			}
+		}
+	}
+
+	failed := 0
+
+	for _, c := range checks {
+		if c.Status == CHECK_FAIL {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%v doctor check(s) failed", failed)
+	}
+
+	return nil
+}
+
+func pass(name, format string, args ...interface{}) *Check {
+	return &Check{Name: name, Status: CHECK_PASS, Message: fmt.Sprintf(format, args...)}
+}
+
+func warn(name, fix, format string, args ...interface{}) *Check {
+	return &Check{Name: name, Status: CHECK_WARN, Message: fmt.Sprintf(format, args...), Fix: fix}
+}
+
+func fail(name, fix, format string, args ...interface{}) *Check {
+	return &Check{Name: name, Status: CHECK_FAIL, Message: fmt.Sprintf(format, args...), Fix: fix}
+}
+
+func (d *Dkenv) checkBinDir() []*Check {
+	if err := CheckWritable(d.BinDir); err != nil {
+		return []*Check{fail("bindir", "Run dkenv with sufficient privileges, or pick another --bindir",
+			"%v is not writable: %v", d.BinDir, err)}
+	}
+
+	return []*Check{pass("bindir", "%v is writable", d.BinDir)}
+}
+
+func (d *Dkenv) checkDkenvDir() []*Check {
+	if err := CheckWritable(d.DkenvDir); err != nil {
+		return []*Check{fail("dkenvdir", fmt.Sprintf("Check the ownership of %v", d.DkenvDir),
+			"%v is not writable: %v", d.DkenvDir, err)}
+	}
+
+	fi, err := os.Stat(d.DkenvDir)
+	if err == nil && fi.Mode().Perm()&0022 != 0 {
+		return []*Check{warn("dkenvdir", fmt.Sprintf("chmod go-w %v", d.DkenvDir),
+			"%v is writable by other users (%v); they could replace docker binaries", d.DkenvDir, fi.Mode().Perm())}
+	}
+
+	return []*Check{pass("dkenvdir", "%v is writable", d.DkenvDir)}
+}
+
+func (d *Dkenv) checkDockerLink() []*Check {
+	dst := d.dockerPath()
+
+	_, err := os.Lstat(dst)
+	if err != nil && os.IsNotExist(err) {
+		return []*Check{warn("symlink", "Run 'dkenv client <version>'", "%v does not exist", dst)}
+	}
+
+	if err != nil {
+		return []*Check{fail("symlink", "", "Unable to stat %v: %v", dst, err)}
+	}
+
+	info, err := d.inspectLink(dst)
+	if err != nil {
+		return []*Check{fail("symlink", "", "Unable to inspect %v: %v", dst, err)}
+	}
+
+	checks := make([]*Check, 0)
+
+	switch {
+	case info.Mode == "" && info.Target == "":
+		return []*Check{warn("symlink", "Run 'dkenv client <version>' (the file will be backed up)",
+			"%v is not managed by dkenv (not a symlink, hardlink or copy of an installed version)", dst)}
+	case info.Target != "" && !resolves(dst):
+		checks = append(checks, fail("symlink", "Run 'dkenv client <version>' or 'dkenv restore'",
+			"%v points at missing file %v", dst, info.Target))
+	case info.Mode == "":
+		checks = append(checks, warn("symlink", "Run 'dkenv client <version>'",
+			"%v points at %v, which is not managed by dkenv", dst, info.Target))
+	case info.Mode != LINK_SHIM && info.Mode != d.versionLinkMode():
+		checks = append(checks, warn("symlink", "Run 'dkenv client <version>' to relink it",
+			"%v was linked in %v mode, but the link mode is %v", dst, info.Mode, d.versionLinkMode()))
+	case info.Target != "":
+		checks = append(checks, pass("symlink", "%v -> %v", dst, info.Target))
+	default:
+		checks = append(checks, pass("symlink", "%v is a %v", dst, info))
+	}
+
+	if changed, current, expected := d.linkChanged(); changed {
+		checks = append(checks, warn("symlink", "Run 'dkenv client <version>' to take control again",
+			"%v was changed outside of dkenv (points at '%v', dkenv last set '%v')", dst, current, expected))
+	}
+
+	return checks
+}
+
+// Report whether path exists, following symlinks
+func resolves(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Make sure 'docker' on PATH is the one dkenv manages
+func (d *Dkenv) checkPath() []*Check {
+	expected := []string{filepath.Clean(d.BinDir)}
+
+	if sessionDir := os.Getenv(SESSION_ENV); sessionDir != "" {
+		expected = append(expected, filepath.Clean(sessionDir))
+	}
+
+	binDirOnPath := false
+
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		dir = filepath.Clean(dir)
+		managed := false
+
+		for _, e := range expected {
+			if dir == e {
+				managed = true
+				binDirOnPath = true
+			}
+		}
+
+		candidate := filepath.Join(dir, "docker")
+
+		fi, err := os.Stat(candidate)
+		if err != nil || fi.IsDir() || fi.Mode().Perm()&0111 == 0 {
+			continue
+		}
+
+		if managed {
+			return []*Check{pass("path", "%v is the first docker on PATH", candidate)}
+		}
+
+		return []*Check{fail("path", fmt.Sprintf("Remove %v or put %v earlier in PATH", candidate, d.BinDir),
+			"%v shadows %v", candidate, d.dockerPath())}
+	}
+
+	if !binDirOnPath {
+		shell := DetectShell()
+
+		return []*Check{fail("path", fmt.Sprintf("Add this line to %v: %v", shellRcFiles[shell], pathHint(shell, d.BinDir)),
+			"%v is not on PATH", d.BinDir)}
+	}
+
+	return []*Check{warn("path", "Run 'dkenv client <version>'", "No docker found on PATH")}
+}
+
+func (d *Dkenv) checkInstalled() []*Check {
+	installed, err := d.listInstalled()
+	if err != nil {
+		return []*Check{fail("binaries", "", "Unable to list %v: %v", d.DkenvDir, err)}
+	}
+
+	checks := make([]*Check, 0)
+
+	for _, version := range installed {
+		path := d.binaryPath(version)
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			checks = append(checks, fail("binaries", "", "Unable to stat %v: %v", path, err))
+			continue
+		}
+
+		if fi.Mode().Perm()&0111 == 0 {
+			checks = append(checks, fail("binaries", fmt.Sprintf("chmod +x %v", path), "%v is not executable", path))
+		}
+	}
+
+	if len(checks) == 0 {
+		checks = append(checks, pass("binaries", "%v installed docker binaries are executable", len(installed)))
+	}
+
+	return checks
+}
+
+// Anything in DkenvDir which dkenv didn't put there
+func (d *Dkenv) checkUnmanagedFiles() []*Check {
+	files, err := ioutil.ReadDir(d.DkenvDir)
+	if err != nil {
+		return []*Check{fail("store", "", "Unable to list %v: %v", d.DkenvDir, err)}
+	}
+
+	known := map[string]bool{
+		STATE_FILE:          true,
+		HISTORY_FILE:        true,
+		GLOBAL_VERSION_FILE: true,
+		LOCK_DIR:            true,
+		SESSION_DIR:         true,
+		META_DIR:            true,
+		VERSIONS_DIR:        true,
+		LAYOUT_FILE:         true,
+		CONFIG_FILE:         true,
+	}
+
+	unmanaged := make([]string, 0)
+
+	for _, fi := range files {
+		name := fi.Name()
+
+		if known[name] {
+			continue
+		}
+
+		unmanaged = append(unmanaged, name)
+	}
+
+	if len(unmanaged) > 0 {
+		return []*Check{warn("store", fmt.Sprintf("Remove or move them out of %v", d.DkenvDir),
+			"Unmanaged files in %v: %v", d.DkenvDir, strings.Join(unmanaged, ", "))}
+	}
+
+	return []*Check{pass("store", "No unmanaged files in %v", d.DkenvDir)}
+}
+
+func (d *Dkenv) checkDaemon() []*Check {
+	daemonApi, err := DaemonApiVersion()
+	if err != nil {
+		return []*Check{warn("daemon", "Check DOCKER_HOST and that the docker daemon is running", "%v", err)}
+	}
+
+	checks := []*Check{pass("daemon", "Docker daemon reachable (API %v)", daemonApi)}
+
+	clientVersion, err := d.installedClientVersion()
+	if err != nil {
+		return checks
+	}
+
+	clientApi, err := d.clientApiVersion(clientVersion)
+	if err == nil && compareVersions(clientApi, daemonApi) > 0 {
+		checks = append(checks, warn("daemon", fmt.Sprintf("Run 'dkenv api %v' or 'eval \"$(dkenv env auto)\"'", daemonApi),
+			"Docker client %v (API %v) is newer than the daemon (API %v)", clientVersion, clientApi, daemonApi))
+	}
+
+	return checks
+}
@@ -0,0 +1,118 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoctorAction(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	oldPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", oldPath)
+
+	os.Unsetenv(SESSION_ENV)
+	os.Setenv("PATH", tmpBinDir)
+	os.Setenv("DOCKER_HOST", "unix://"+tmpDkenvDir+"/nonexistent.sock")
+	defer os.Unsetenv("DOCKER_HOST")
+
+	out := &bytes.Buffer{}
+
+	d := New(tmpDkenvDir, tmpBinDir)
+	d.Out = out
+
+	installFake(t, tmpDkenvDir, "1.9.1")
+
+	assert.NoError(t, d.UpdateSymlink("1.9.1"))
+
+	// All good (the daemon being unreachable is only a warning)
+	assert.NoError(t, d.DoctorAction(false))
+	assert.Contains(t, out.String(), "[PASS] path:")
+	assert.Contains(t, out.String(), "[WARN] daemon:")
+
+	// Non-executable binary, unmanaged file, dangling symlink
+	os.Chmod(d.binaryPath("1.9.1"), 0644)
+	ioutil.WriteFile(tmpDkenvDir+"/random.txt", []byte("?"), 0644)
+	out.Reset()
+
+	assert.Error(t, d.DoctorAction(true))
+
+	var checks []*Check
+
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &checks))
+
+	statuses := make(map[string]string)
+
+	for _, c := range checks {
+		if statuses[c.Name] != CHECK_FAIL {
+			statuses[c.Name] = c.Status
+		}
+	}
+
+	assert.Equal(t, CHECK_FAIL, statuses["binaries"])
+	assert.Equal(t, CHECK_WARN, statuses["store"])
+
+	os.Remove(d.binaryPath("1.9.1"))
+	out.Reset()
+
+	assert.Error(t, d.DoctorAction(false))
+	assert.Contains(t, out.String(), "[FAIL] symlink:")
+}
+
+func TestCheckPath(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	oldPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", oldPath)
+
+	os.Unsetenv(SESSION_ENV)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+
+	// BinDir not on PATH
+	os.Setenv("PATH", "/nonexistent")
+	assert.Equal(t, CHECK_FAIL, d.checkPath()[0].Status)
+
+	// Another docker earlier on PATH
+	ioutil.WriteFile(tmpDkenvDir+"/docker", []byte("other"), 0755)
+	ioutil.WriteFile(tmpBinDir+"/docker", []byte("ours"), 0755)
+
+	os.Setenv("PATH", tmpDkenvDir+":"+tmpBinDir)
+	check := d.checkPath()[0]
+	assert.Equal(t, CHECK_FAIL, check.Status)
+	assert.Contains(t, check.Message, "shadows")
+
+	os.Setenv("PATH", tmpBinDir+":"+tmpDkenvDir)
+	assert.Equal(t, CHECK_PASS, d.checkPath()[0].Status)
+}
+
+func TestCheckUnmanagedFiles(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+
+	installFake(t, tmpDkenvDir, "1.9.1")
+	assert.NoError(t, d.GlobalAction("1.9.1"))
+
+	// The user config lives here when DKENV_DIR is set
+	assert.NoError(t, ioutil.WriteFile(tmpDkenvDir+"/"+CONFIG_FILE, []byte("link_mode: copy\n"), 0644))
+	assert.Equal(t, CHECK_PASS, d.checkUnmanagedFiles()[0].Status)
+
+	assert.NoError(t, ioutil.WriteFile(tmpDkenvDir+"/random.txt", []byte("?"), 0644))
+
+	check := d.checkUnmanagedFiles()[0]
+	assert.Equal(t, CHECK_WARN, check.Status)
+	assert.Contains(t, check.Message, "random.txt")
+	assert.NotContains(t, check.Message, CONFIG_FILE)
+}
@@ -1,16 +1,28 @@
 package lib
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 )
 
+const (
+	PARTIAL_PREFIX = ".download-"
+
+	DEFAULT_MIRROR = "https://get.docker.com/builds"
+)
+
 var (
 	errResource          = errors.New("invalid webfinger resource")
 	errNotYetImplemented = errors.New("Not yet implemented")
@@ -32,34 +44,160 @@ type PassThru struct {
 }
 
 func (d *Dkenv) DownloadDocker(version string) error {
-	resp, err := d.getHttp(version)
+	return d.download(version, nil)
+}
+
+// Download and install a version. With a lockfile entry, the binary comes
+// from the locked URL (or channel) and has to match the locked digest.
+func (d *Dkenv) download(version string, locked *LockEntry) error {
+	l, err := d.lock("download-" + version)
 	if err != nil {
 		return err
 	}
+	defer l.unlock()
+
+	// Another dkenv process may have finished the download while we waited
+	if d.isInstalled(version) {
+		log.Infof("Docker version %v was installed by another dkenv process", version)
+		return nil
+	}
+
+	var body []byte
+	var sourceURL, mirror, channel string
+
+	if locked != nil && locked.URL != "" {
+		sourceURL, channel = locked.URL, locked.Channel
+
+		if body, err = d.downloadURL(sourceURL); err != nil {
+			log.Warningf("%v - trying the usual download locations", err)
+		}
+	}
+
+	if body == nil {
+		channel = ""
+
+		// Releases since 17.03 are published per channel (see channel.go)
+		if isChannelVersion(version) {
+			channel = d.channel()
+
+			if locked != nil && locked.Channel != "" {
+				channel = locked.Channel
+			}
+
+			body, sourceURL, err = d.downloadChannel(version, channel)
+		} else {
+			body, sourceURL, mirror, err = d.downloadLegacy(version)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	checksum := fmt.Sprintf("%x", sha256.Sum256(body))
+
+	// Don't install a binary nobody vouched for
+	if locked != nil && checksum != locked.SHA256 {
+		return fmt.Errorf("Downloaded docker %v (%v) has sha256 %v, but the lockfile expects %v",
+			version, sourceURL, checksum, locked.SHA256)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(d.binaryPath(version)), 0755); err != nil {
+		return fmt.Errorf("Unable to create directory for docker %v: %v", version, err)
+	}
+
+	// Download to a partial file first, so an interrupted download never
+	// looks like an installed version
+	if err := writeFileVia(PARTIAL_PREFIX+version+"-", d.binaryPath(version), body, 0755); err != nil {
+		return fmt.Errorf("Error(s) writing docker binary: %v", err)
+	}
+
+	meta := &InstallMeta{
+		Version:     version,
+		SourceURL:   sourceURL,
+		Mirror:      mirror,
+		Channel:     channel,
+		SHA256:      checksum,
+		Size:        int64(len(body)),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		InstalledAt: time.Now(),
+	}
+
+	meta.APIVersion, _ = VersionToApi(version)
+
+	return d.writeMeta(meta)
+}
+
+// Fetch a docker binary (or a release archive containing one) from a URL, as
+// recorded in a lockfile
+func (d *Dkenv) downloadURL(source string) ([]byte, error) {
+	client, err := d.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Downloading %v", source)
+
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to download %v: %v", source, err)
+	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Unable to download %v: %v", source, resp.Status)
+	}
+
 	readerpt := &PassThru{Reader: resp.Body, length: resp.ContentLength}
 
 	body, err := ioutil.ReadAll(readerpt)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	contentType := http.DetectContentType(body)
-	if contentType != "application/octet-stream" {
-		return fmt.Errorf("Content-Type mismatch: %s detected", contentType)
+	if !strings.HasSuffix(source, ".tgz") {
+		return body, nil
 	}
 
-	if err := ioutil.WriteFile(d.DkenvDir+"/docker-"+version, body, 0755); err != nil {
-		return fmt.Errorf("Error(s) writing docker binary: %v", err)
+	binary, err := extractClient(body)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to extract docker from %v: %v", source, err)
 	}
 
-	return nil
+	return binary, nil
 }
 
-func (d *Dkenv) getHttp(version string) (*http.Response, error) {
-	client := &http.Client{
-		CheckRedirect: redirectPolicyFunc,
+// Fetch a plain docker binary from the mirrors; also returns the URL it was
+// served from and the mirror
+func (d *Dkenv) downloadLegacy(version string) ([]byte, string, string, error) {
+	resp, mirror, err := d.getHttp(version)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer resp.Body.Close()
+
+	readerpt := &PassThru{Reader: resp.Body, length: resp.ContentLength}
+
+	body, err := ioutil.ReadAll(readerpt)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	contentType := http.DetectContentType(body)
+	if contentType != "application/octet-stream" {
+		return nil, "", "", fmt.Errorf("Content-Type mismatch: %s detected", contentType)
+	}
+
+	return body, resp.Request.URL.String(), mirror, nil
+}
+
+// Fetch a docker binary, trying each mirror in turn; also returns the mirror
+// which served it
+func (d *Dkenv) getHttp(version string) (*http.Response, string, error) {
+	client, err := d.httpClient()
+	if err != nil {
+		return nil, "", err
 	}
 
 	var system string
@@ -72,20 +210,36 @@ func (d *Dkenv) getHttp(version string) (*http.Response, error) {
 	case runtime.GOOS == "darwin":
 		system = "Darwin"
 	default:
-		return nil, fmt.Errorf("Unsupported system type - %v", runtime.GOOS)
+		return nil, "", fmt.Errorf("Unsupported system type - %v", runtime.GOOS)
 	}
 
-	resp, err := client.Get("https://get.docker.com/builds/" + system + "/x86_64/docker-" + version)
-	if err != nil {
-		return nil, err
+	mirrors := d.Mirrors
+	if len(mirrors) == 0 {
+		mirrors = []string{DEFAULT_MIRROR}
 	}
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("No such docker version '%v'", version)
-	}
+	err = fmt.Errorf("No such docker version '%v'", version)
+
+	for _, mirror := range mirrors {
+		mirror = strings.TrimRight(mirror, "/")
+
+		resp, getErr := client.Get(mirror + "/" + system + "/x86_64/docker-" + version)
+		if getErr != nil {
+			log.Warningf("Unable to download docker %v from %v: %v", version, mirror, getErr)
+			err = getErr
+			continue
+		}
+
+		if resp.StatusCode != 200 {
+			log.Debugf("Mirror %v returned %v for docker %v", mirror, resp.Status, version)
+			resp.Body.Close()
+			continue
+		}
 
-	return resp, nil
+		return resp, mirror, nil
+	}
 
+	return nil, "", err
 }
 
 func (pt *PassThru) Read(p []byte) (int, error) {
@@ -103,6 +257,24 @@ func (pt *PassThru) Read(p []byte) (int, error) {
 	return n, err
 }
 
+// HTTP client for downloads, going through Proxy if set
+func (d *Dkenv) httpClient() (*http.Client, error) {
+	client := &http.Client{
+		CheckRedirect: redirectPolicyFunc,
+	}
+
+	if d.Proxy != "" {
+		proxy, err := url.Parse(d.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid proxy '%v': %v", d.Proxy, err)
+		}
+
+		client.Transport = &http.Transport{Proxy: http.ProxyURL(proxy)}
+	}
+
+	return client, nil
+}
+
 func redirectPolicyFunc(req *http.Request, via []*http.Request) error {
 	if len(via) > 10 {
 		return errTooManyRedirects
@@ -0,0 +1,149 @@
+package lib
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// Oldest API version which a newer client can be pinned to via
+	// DOCKER_API_VERSION
+	MIN_PIN_API = "1.12"
+)
+
+var (
+	clientVersionRegex = regexp.MustCompile(`version ([0-9][^,\s]*)`)
+
+	// API versions are always major.minor, eg. "1.24"
+	apiRegex = regexp.MustCompile(`^[0-9]+\.[0-9]+$`)
+)
+
+// Print shell exports which pin DOCKER_API_VERSION for the current shell.
+//
+// If apiVersion is "auto", the API version is looked up from the daemon. If
+// the currently installed client is too old to speak the requested API
+// version, fall back to switching the docker binary instead.
+func (d *Dkenv) EnvAction(apiVersion, shell string) error {
+	if shell == "" {
+		shell = DetectShell()
+	}
+
+	if apiVersion == "auto" {
+		var err error
+
+		apiVersion, err = DaemonApiVersion()
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Docker daemon reports API version '%v'", apiVersion)
+	}
+
+	if !apiRegex.MatchString(apiVersion) {
+		return fmt.Errorf("Invalid API version '%v' - expected major.minor, eg. 1.24", apiVersion)
+	}
+
+	if compareVersions(apiVersion, MIN_PIN_API) < 0 {
+		return fmt.Errorf("Unable to pin API version '%v' - minimum supported is %v", apiVersion, MIN_PIN_API)
+	}
+
+	if ok, reason := d.canPinApi(apiVersion); !ok {
+		log.Warningf("%v - falling back to switching docker binary", reason)
+
+		if err := d.FetchVersionAction(apiVersion, true); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(d.Out, unsetLine(shell, "DOCKER_API_VERSION"))
+
+		return nil
+	}
+
+	fmt.Fprintln(d.Out, exportLine(shell, "DOCKER_API_VERSION", apiVersion))
+
+	return nil
+}
+
+// Check whether the installed docker client is new enough to be pinned to a
+// given API version
+func (d *Dkenv) canPinApi(apiVersion string) (bool, string) {
+	clientVersion, err := d.installedClientVersion()
+	if err != nil {
+		return false, fmt.Sprintf("Unable to determine installed docker client version: %v", err)
+	}
+
+	clientApi, err := d.clientApiVersion(clientVersion)
+	if err != nil {
+		// Every client newer than the API table understands
+		// DOCKER_API_VERSION, so only old clients have to be known
+		if compareVersions(clientVersion, newestTableClient()) > 0 {
+			return true, ""
+		}
+
+		return false, fmt.Sprintf("Unable to determine API version for docker client '%v'", clientVersion)
+	}
+
+	if compareVersions(clientApi, apiVersion) < 0 {
+		return false, fmt.Sprintf("Installed docker client '%v' (API %v) is too old for API version '%v'",
+			clientVersion, clientApi, apiVersion)
+	}
+
+	return true, ""
+}
+
+// Figure out the version of the docker client in BinDir; prefer the version
+// of the store binary it links to, but fall back to asking the binary itself
+func (d *Dkenv) installedClientVersion() (string, error) {
+	dst := d.dockerPath()
+
+	if info, err := d.inspectLink(dst); err == nil && info.Version != "" {
+		return info.Version, nil
+	}
+
+	out, err := exec.Command(dst, "--version").Output()
+	if err != nil {
+		return "", err
+	}
+
+	match := clientVersionRegex.FindStringSubmatch(string(out))
+	if match == nil {
+		return "", fmt.Errorf("Unexpected output from '%v --version': %v", dst, strings.TrimSpace(string(out)))
+	}
+
+	return match[1], nil
+}
+
+// Figure out the API version spoken by the docker client in BinDir. Clients
+// newer than the API table are asked directly; 'docker version' exits
+// non-zero without a daemon, but still prints the client part.
+func (d *Dkenv) clientApiVersion(clientVersion string) (string, error) {
+	if api, err := VersionToApi(clientVersion); err == nil {
+		return api, nil
+	}
+
+	out, _ := exec.Command(d.dockerPath(), "version", "--format", "{{.Client.APIVersion}}").Output()
+
+	api := strings.TrimSpace(string(out))
+	if !apiRegex.MatchString(api) {
+		return "", fmt.Errorf("Unable to determine API version for docker client '%v'", clientVersion)
+	}
+
+	return api, nil
+}
+
+// Return the newest client version in the API table
+func newestTableClient() string {
+	newest := ""
+
+	for _, clientVersion := range apiVersions {
+		if newest == "" || compareVersions(clientVersion, newest) > 0 {
+			newest = clientVersion
+		}
+	}
+
+	return newest
+}
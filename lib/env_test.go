@@ -0,0 +1,88 @@
+package lib
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvAction(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	out := &bytes.Buffer{}
+
+	d := New(tmpDkenvDir, tmpBinDir)
+	d.Out = out
+
+	// Client speaks API 1.22, so anything older can be pinned
+	if err := os.Symlink(d.binaryPath("1.10.3"), tmpBinDir+"/docker"); err != nil {
+		t.Fatalf("Unable to create test symlink: %v", err)
+	}
+
+	assert.NoError(t, d.EnvAction("1.18", "bash"))
+	assert.Equal(t, "export DOCKER_API_VERSION='1.18'\n", out.String())
+
+	// Below the minimum pinnable version
+	assert.Error(t, d.EnvAction("1.11", "bash"))
+
+	ok, _ := d.canPinApi("1.22")
+	assert.True(t, ok)
+
+	ok, _ = d.canPinApi("1.23")
+	assert.False(t, ok)
+}
+
+func TestEnvActionModernClient(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake docker client is a shell script")
+	}
+
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	out := &bytes.Buffer{}
+
+	d := New(tmpDkenvDir, tmpBinDir)
+	d.Out = out
+
+	// Newer than the API table, so the client is asked directly
+	installFake(t, tmpDkenvDir, "17.06.0-ce")
+
+	script := "#!/bin/sh\necho 1.30\n"
+	if err := ioutil.WriteFile(d.binaryPath("17.06.0-ce"), []byte(script), 0755); err != nil {
+		t.Fatalf("Unable to create fake docker client: %v", err)
+	}
+
+	if err := os.Symlink(d.binaryPath("17.06.0-ce"), tmpBinDir+"/docker"); err != nil {
+		t.Fatalf("Unable to create test symlink: %v", err)
+	}
+
+	assert.NoError(t, d.EnvAction("1.24", "bash"))
+	assert.Equal(t, "export DOCKER_API_VERSION='1.24'\n", out.String())
+
+	ok, _ := d.canPinApi("1.30")
+	assert.True(t, ok)
+
+	ok, _ = d.canPinApi("1.31")
+	assert.False(t, ok)
+
+	// A modern client which can't report its API version can still be pinned
+	installFake(t, tmpDkenvDir, "17.06.0-ce")
+
+	ok, _ = d.canPinApi("1.30")
+	assert.True(t, ok)
+
+	// Malformed API versions are never exported
+	out.Reset()
+
+	assert.Error(t, d.EnvAction("1.24'; rm -rf ~; '", "bash"))
+	assert.Error(t, d.EnvAction("1.24.1", "bash"))
+	assert.Empty(t, out.String())
+}
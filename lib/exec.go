@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"os"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Run a specific docker version without touching the docker symlink,
+// downloading it first if necessary. A leading "docker" in args is optional
+// ("dkenv exec 1.6.2 -- docker ps" == "dkenv exec 1.6.2 -- ps"). If apiVersion
+// is set, DOCKER_API_VERSION is set for the command. Only returns on error.
+func (d *Dkenv) ExecAction(version string, args []string, apiVersion string) error {
+	version, err := d.resolveAlias(version)
+	if err != nil {
+		return err
+	}
+
+	if !d.isInstalled(version) {
+		log.Infof("Docker version %v not found - attempting to download...", version)
+
+		if err := d.DownloadDocker(version); err != nil {
+			return err
+		}
+	}
+
+	d.touchVersion(version)
+
+	if len(args) > 0 && args[0] == "docker" {
+		args = args[1:]
+	}
+
+	env := os.Environ()
+
+	if apiVersion != "" {
+		env = setEnv(env, "DOCKER_API_VERSION", apiVersion)
+	}
+
+	return execBinary(d.binaryPath(version), args, env)
+}
+
+// Return a copy of env (as returned by os.Environ) with name set to value
+func setEnv(env []string, name, value string) []string {
+	result := make([]string, 0, len(env)+1)
+
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, name+"=") {
+			result = append(result, kv)
+		}
+	}
+
+	return append(result, name+"="+value)
+}
@@ -0,0 +1,17 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetEnv(t *testing.T) {
+	env := []string{"PATH=/bin", "DOCKER_API_VERSION=1.18", "DOCKER_API_VERSION_X=1"}
+
+	result := setEnv(env, "DOCKER_API_VERSION", "1.21")
+	assert.Equal(t, []string{"PATH=/bin", "DOCKER_API_VERSION_X=1", "DOCKER_API_VERSION=1.21"}, result)
+
+	// Original is untouched
+	assert.Equal(t, "DOCKER_API_VERSION=1.18", env[1])
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package lib
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// Replace the current process with a docker binary. Since the process is
+// replaced, stdio, signals and the exit status belong to docker directly.
+func execBinary(path string, args, env []string) error {
+	argv := append([]string{"docker"}, args...)
+
+	if err := syscall.Exec(path, argv, env); err != nil {
+		return fmt.Errorf("Unable to exec '%v': %v", path, err)
+	}
+
+	return nil
+}
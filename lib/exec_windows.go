@@ -0,0 +1,50 @@
+//go:build windows
+// +build windows
+
+package lib
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// Run a docker binary as a child process (Windows has no exec(2)), forwarding
+// stdio and interrupts, then exit with its exit status
+func execBinary(path string, args, env []string) error {
+	cmd := exec.Command(path, args...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Unable to run '%v': %v", path, err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	err := cmd.Wait()
+	signal.Stop(signals)
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		os.Exit(exitErr.Sys().(syscall.WaitStatus).ExitStatus())
+	}
+
+	if err != nil {
+		return fmt.Errorf("Unable to run '%v': %v", path, err)
+	}
+
+	os.Exit(0)
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	// Replaced in tests to simulate filesystems without hardlinks
+	linkFile = os.Link
+)
+
+// Write a file by writing to a temporary file in the same directory and
+// renaming it into place, so readers never see a partially written file
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	return writeFileVia("."+filepath.Base(filename)+".tmp", filename, data, perm)
+}
+
+// Same as writeFileAtomic, with control over the temporary file's name prefix
+func writeFileVia(tmpPrefix, filename string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), tmpPrefix)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// Return the hex encoded sha256 of a file's contents
+func sha256File(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("Unable to checksum '%v': %v", filename, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Format a byte count for humans (eg. "12.3 MiB")
+func humanBytes(n int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+
+	value := float64(n)
+	i := 0
+
+	for ; value >= 1024 && i < len(units)-1; i++ {
+		value /= 1024
+	}
+
+	if i == 0 {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	return fmt.Sprintf("%.1f %v", value, units[i])
+}
+
+// Make dst a hardlink of src, falling back to a copy on filesystems without
+// hardlinks (or across devices)
+func linkOrCopy(src, dst string) error {
+	err := linkFile(src, dst)
+	if err == nil {
+		return nil
+	}
+
+	log.Debugf("Unable to hardlink '%v' to '%v' (%v) - copying it instead", src, dst, err)
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	err = copyFile(src, dst, info.Mode().Perm())
+	if err != nil && !os.IsExist(err) {
+		os.Remove(dst)
+	}
+
+	return err
+}
+
+// Check that files can be created in dir
+func CheckWritable(dir string) error {
+	f, err := ioutil.TempFile(dir, ".dkenv-write-test")
+	if err != nil {
+		return err
+	}
+
+	f.Close()
+
+	return os.Remove(f.Name())
+}
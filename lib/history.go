@@ -0,0 +1,145 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	HISTORY_FILE = "history.log"
+)
+
+// A single docker symlink switch
+type HistoryEntry struct {
+	Time    time.Time `json:"time"`
+	From    string    `json:"from,omitempty"`
+	To      string    `json:"to"`
+	Trigger string    `json:"trigger,omitempty"`
+}
+
+func (d *Dkenv) historyPath() string {
+	return d.DkenvDir + "/" + HISTORY_FILE
+}
+
+// Append a switch to the history log (one JSON object per line)
+func (d *Dkenv) recordSwitch(from, to string) error {
+	entry := &HistoryEntry{
+		Time:    time.Now(),
+		From:    from,
+		To:      to,
+		Trigger: d.Trigger,
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(d.historyPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("Unable to open history log: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("Unable to write history log: %v", err)
+	}
+
+	return nil
+}
+
+// Read the history log, oldest entry first
+func (d *Dkenv) readHistory() ([]*HistoryEntry, error) {
+	entries := make([]*HistoryEntry, 0)
+
+	f, err := os.Open(d.historyPath())
+	if err != nil && os.IsNotExist(err) {
+		return entries, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open history log: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		entry := &HistoryEntry{}
+
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			log.Debugf("Skipping unparseable history entry: %v", err)
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Unable to read history log: %v", err)
+	}
+
+	return entries, nil
+}
+
+// Show the most recent 'limit' switches (all if limit is 0)
+func (d *Dkenv) HistoryAction(limit int) error {
+	entries, err := d.readHistory()
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		log.Warning("No docker switches recorded yet!")
+		return nil
+	}
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	for _, e := range entries {
+		from := e.From
+		if from == "" {
+			from = "(none)"
+		}
+
+		log.Infof("%v: %v -> %v (%v)", e.Time.Format(time.RFC3339), from, e.To, e.Trigger)
+	}
+
+	return nil
+}
+
+// Switch to an installed version or alias; "-" switches back to the previous
+// version. Never downloads anything.
+func (d *Dkenv) UseAction(version string) error {
+	if version != "-" {
+		var err error
+
+		if version, err = d.resolveAlias(version); err != nil {
+			return err
+		}
+	} else {
+		entries, err := d.readHistory()
+		if err != nil {
+			return err
+		}
+
+		if len(entries) == 0 || entries[len(entries)-1].From == "" {
+			return fmt.Errorf("No previous docker version to switch back to")
+		}
+
+		version = entries[len(entries)-1].From
+	}
+
+	if !d.isInstalled(version) {
+		return fmt.Errorf("Docker version %v is not installed - use 'dkenv client %v' to install it", version, version)
+	}
+
+	return d.FetchVersionAction(version, false)
+}
@@ -0,0 +1,80 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryAndUse(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+	d.Trigger = "dkenv test"
+
+	for _, version := range []string{"1.8.3", "1.9.1"} {
+		installFake(t, tmpDkenvDir, version)
+	}
+
+	// Nothing to go back to yet
+	assert.Error(t, d.UseAction("-"))
+	assert.NoError(t, d.HistoryAction(0))
+
+	assert.NoError(t, d.UseAction("1.8.3"))
+	assert.NoError(t, d.UseAction("1.9.1"))
+
+	// Noop switches aren't recorded
+	assert.NoError(t, d.UseAction("1.9.1"))
+
+	entries, err := d.readHistory()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "", entries[0].From)
+	assert.Equal(t, "1.8.3", entries[0].To)
+	assert.Equal(t, "1.8.3", entries[1].From)
+	assert.Equal(t, "1.9.1", entries[1].To)
+	assert.Equal(t, "dkenv test", entries[1].Trigger)
+
+	// Toggle back and forth
+	assert.NoError(t, d.UseAction("-"))
+	assertLink(t, d.dockerPath(), d.binaryPath("1.8.3"))
+
+	assert.NoError(t, d.UseAction("-"))
+	assertLink(t, d.dockerPath(), d.binaryPath("1.9.1"))
+
+	// Never downloads
+	assert.Error(t, d.UseAction("1.0.0"))
+
+	assert.NoError(t, d.HistoryAction(2))
+}
+
+func TestUseAliasAndShim(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+	d.LinkMode = LINK_SHIM
+
+	for _, version := range []string{"1.8.3", "1.9.1"} {
+		installFake(t, tmpDkenvDir, version)
+	}
+
+	// Aliases are resolved before checking what's installed
+	assert.NoError(t, d.AliasAction("prod", "1.8.3", false))
+	assert.NoError(t, d.UseAction("prod"))
+	assert.NoError(t, d.UseAction(ALIAS_LATEST_INSTALLED))
+
+	version, err := readVersionFile(d.globalVersionPath())
+	assert.NoError(t, err)
+	assert.Equal(t, "1.9.1", version)
+
+	// Switches are recorded in shim mode too, so they can be toggled
+	assert.NoError(t, d.UseAction("-"))
+
+	version, err = readVersionFile(d.globalVersionPath())
+	assert.NoError(t, err)
+	assert.Equal(t, "1.8.3", version)
+}
@@ -0,0 +1,135 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	LAST_DIR_ENV     = "DKENV_LAST_DIR"
+	LAST_VERSION_ENV = "DKENV_LAST_VERSION"
+)
+
+var (
+	hookTemplates = map[string]string{
+		"bash": `_dkenv_hook() {
+  local previous_exit_status=$?
+  if [ "$PWD" != "$DKENV_LAST_DIR" ]; then
+    eval "$(%v)"
+  fi
+  return $previous_exit_status
+}
+if [[ ";${PROMPT_COMMAND:-};" != *";_dkenv_hook;"* ]]; then
+  PROMPT_COMMAND="_dkenv_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`,
+		"zsh": `_dkenv_hook() {
+  if [[ "$PWD" != "$DKENV_LAST_DIR" ]]; then
+    eval "$(%v)"
+  fi
+}
+typeset -ag precmd_functions
+if (( ! ${precmd_functions[(I)_dkenv_hook]} )); then
+  precmd_functions=(_dkenv_hook $precmd_functions)
+fi
+`,
+		"fish": `function _dkenv_hook --on-variable PWD --description 'Switch docker version on directory change'
+  if test "$PWD" != "$DKENV_LAST_DIR"
+    %v | source
+  end
+end
+_dkenv_hook
+`,
+	}
+)
+
+// Print a shell hook which switches the session's docker version whenever the
+// working directory changes. The hook itself only calls out to dkenv when the
+// directory differs from the last one it resolved.
+func (d *Dkenv) HookAction(shell string, autoInstall bool) error {
+	tmpl, ok := hookTemplates[shell]
+	if !ok {
+		return fmt.Errorf("Unsupported shell '%v' for hook", shell)
+	}
+
+	exe, err := shimTarget()
+	if err != nil {
+		return err
+	}
+
+	quote := quotePosix
+	if shell == "fish" {
+		quote = quoteFish
+	}
+
+	args := []string{
+		quote(exe),
+		"--bindir", quote(d.BinDir),
+		"--dkenvdir", quote(d.DkenvDir),
+		"hook-env", "--shell", shell,
+	}
+
+	if autoInstall {
+		args = append(args, "--auto-install")
+	}
+
+	fmt.Fprintf(d.Out, tmpl, strings.Join(args, " "))
+
+	return nil
+}
+
+// Called by the shell hook: resolve the version for dir and print shell code
+// switching the session to it (if it changed since the last call)
+func (d *Dkenv) HookEnvAction(dir, shell string, autoInstall bool) error {
+	lines := []string{exportLine(shell, LAST_DIR_ENV, dir)}
+
+	res, err := d.ResolveVersion(dir)
+	if err != nil && err != errNoVersion {
+		return err
+	}
+
+	switch {
+	case err == errNoVersion:
+		// Nothing applies here; drop back to whatever docker is on PATH
+		if os.Getenv(SESSION_ENV) != "" {
+			unset, err := d.unsetSession(shell)
+			if err != nil {
+				return err
+			}
+
+			lines = append(lines, unset...)
+		}
+
+		lines = append(lines, unsetLine(shell, LAST_VERSION_ENV))
+	case res.Version == os.Getenv(LAST_VERSION_ENV) && os.Getenv(SESSION_ENV) != "":
+		// Nothing changed
+	case !d.isInstalled(res.Version) && !autoInstall:
+		log.Warningf("Docker version %v (set by %v) is not installed - use 'dkenv client %v' to install it",
+			res.Version, res.Origin, res.Version)
+	default:
+		if !d.isInstalled(res.Version) {
+			log.Infof("Docker version %v not found - attempting to download...", res.Version)
+
+			if err := d.DownloadDocker(res.Version); err != nil {
+				return err
+			}
+		}
+
+		switched, err := d.switchSession(res.Version, shell)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Switched to docker %v (set by %v)", res.Version, res.Origin)
+
+		lines = append(lines, switched...)
+		lines = append(lines, exportLine(shell, LAST_VERSION_ENV, res.Version))
+	}
+
+	d.printLines(lines...)
+
+	return nil
+}
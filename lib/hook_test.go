@@ -0,0 +1,75 @@
+package lib
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHookAction(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	d := New("/tmp/dkenv dir", "/usr/local/bin")
+	d.Out = out
+
+	assert.NoError(t, d.HookAction("bash", true))
+	assert.Contains(t, out.String(), "PROMPT_COMMAND")
+	assert.Contains(t, out.String(), "--dkenvdir '/tmp/dkenv dir' hook-env --shell bash --auto-install")
+
+	out.Reset()
+
+	assert.NoError(t, d.HookAction("fish", false))
+	assert.Contains(t, out.String(), "--on-variable PWD")
+	assert.NotContains(t, out.String(), "--auto-install")
+
+	assert.Error(t, d.HookAction("powershell", false))
+}
+
+func TestHookEnvAction(t *testing.T) {
+	tmpDkenvDir, tmpProjectDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpProjectDir)
+
+	installFake(t, tmpDkenvDir, "1.9.1")
+
+	for _, name := range []string{SESSION_ENV, LAST_DIR_ENV, LAST_VERSION_ENV} {
+		os.Unsetenv(name)
+		defer os.Unsetenv(name)
+	}
+
+	out := &bytes.Buffer{}
+
+	d := New(tmpDkenvDir, tmpProjectDir)
+	d.Out = out
+
+	// Nothing configured; only the directory is remembered
+	assert.NoError(t, d.HookEnvAction(tmpProjectDir, "bash", false))
+	assert.Contains(t, out.String(), "export "+LAST_DIR_ENV+"='"+tmpProjectDir+"'")
+	assert.NotContains(t, out.String(), SESSION_ENV+"=")
+
+	// Not installed and no auto-install; no switch
+	assert.NoError(t, d.LocalAction("1.8.3", "", tmpProjectDir))
+	out.Reset()
+
+	assert.NoError(t, d.HookEnvAction(tmpProjectDir, "bash", false))
+	assert.NotContains(t, out.String(), SESSION_ENV+"=")
+
+	// Installed; switches the session
+	assert.NoError(t, d.LocalAction("1.9.1", "", tmpProjectDir))
+	out.Reset()
+
+	assert.NoError(t, d.HookEnvAction(tmpProjectDir, "bash", false))
+	assert.Contains(t, out.String(), "export "+SESSION_ENV+"=")
+	assert.Contains(t, out.String(), "export "+LAST_VERSION_ENV+"='1.9.1'")
+
+	// Same version as last time; nothing to do
+	dir, _ := d.sessionDir()
+	os.Setenv(SESSION_ENV, dir)
+	os.Setenv(LAST_VERSION_ENV, "1.9.1")
+	out.Reset()
+
+	assert.NoError(t, d.HookEnvAction(tmpProjectDir, "bash", false))
+	assert.Equal(t, "export "+LAST_DIR_ENV+"='"+tmpProjectDir+"'\n", out.String())
+}
@@ -0,0 +1,235 @@
+package lib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	LAYOUT_FILE  = "layout"
+	VERSIONS_DIR = "versions"
+
+	// 1: flat DkenvDir/docker-<version> files (no layout file)
+	// 2: DkenvDir/versions/<version>/<os>-<arch>/bin/docker
+	STORE_LAYOUT = 2
+)
+
+func (d *Dkenv) layoutPath() string {
+	return d.DkenvDir + "/" + LAYOUT_FILE
+}
+
+// Return the layout version of the store; stores without a layout file
+// predate it and are flat
+func (d *Dkenv) storeLayout() (int, error) {
+	data, err := ioutil.ReadFile(d.layoutPath())
+	if err != nil && os.IsNotExist(err) {
+		return 1, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("Unable to read store layout: %v", err)
+	}
+
+	layout, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("Invalid store layout in '%v': %v", d.layoutPath(), err)
+	}
+
+	return layout, nil
+}
+
+// Directory name for binaries built for the current system
+func platform() string {
+	return runtime.GOOS + "-" + runtime.GOARCH
+}
+
+// Directory name for binaries from a flat store: dkenv only ever downloaded
+// x86_64 builds before the versioned layout, whatever the host
+func legacyPlatform() string {
+	return runtime.GOOS + "-amd64"
+}
+
+// Location a flat store binary is migrated to; same as binaryPath on amd64
+func (d *Dkenv) legacyBinaryPath(version string) string {
+	return d.DkenvDir + "/" + VERSIONS_DIR + "/" + version + "/" + legacyPlatform() + "/bin/docker"
+}
+
+// Report whether the store needs migrating; a single file read, so cheap
+// enough to check before every docker invocation through the shim
+func (d *Dkenv) StoreOutdated() bool {
+	layout, err := d.storeLayout()
+
+	return err == nil && layout < STORE_LAYOUT
+}
+
+// Move a flat store to the current layout, repointing the docker symlink (and
+// shell session links) at the new locations. Binaries are linked (or copied)
+// to their new location first and only removed once nothing points at them
+// any more, so docker never dangles. Every step is idempotent and the layout
+// file is only written at the end, so an interrupted migration simply resumes
+// on the next run.
+func (d *Dkenv) MigrateStore() error {
+	layout, err := d.storeLayout()
+	if err != nil {
+		return err
+	}
+
+	if layout > STORE_LAYOUT {
+		return fmt.Errorf("The store in %v was created by a newer dkenv (layout %v, supported %v)",
+			d.DkenvDir, layout, STORE_LAYOUT)
+	}
+
+	if layout == STORE_LAYOUT {
+		return nil
+	}
+
+	l, err := d.lock("migrate")
+	if err != nil {
+		return err
+	}
+	defer l.unlock()
+
+	// Another dkenv process may have migrated while we waited
+	if layout, err := d.storeLayout(); err != nil || layout == STORE_LAYOUT {
+		return err
+	}
+
+	var flat []string
+
+	paths, _ := filepath.Glob(d.DkenvDir + "/docker-*")
+
+	for _, path := range paths {
+		if fi, err := os.Stat(path); err != nil || fi.IsDir() {
+			continue
+		}
+
+		if _, ok := d.flatVersion(path); !ok {
+			log.Warningf("Leaving '%v' in place - '%v' is not a docker version", path, strings.TrimPrefix(filepath.Base(path), "docker-"))
+			continue
+		}
+
+		flat = append(flat, path)
+	}
+
+	if len(flat) > 0 {
+		log.Infof("Migrating %v docker binaries in %v to the new store layout", len(flat), d.DkenvDir)
+	}
+
+	if len(flat) > 0 && legacyPlatform() != platform() {
+		log.Warningf("Docker binaries in %v are x86_64 builds - moving them to %v, where dkenv won't use them on %v",
+			d.DkenvDir, legacyPlatform(), platform())
+	}
+
+	for _, path := range flat {
+		version, _ := d.flatVersion(path)
+
+		if err := migrateBinary(path, d.legacyBinaryPath(version)); err != nil {
+			return err
+		}
+	}
+
+	if err := d.migrateLinks(); err != nil {
+		return err
+	}
+
+	for _, path := range flat {
+		log.Debugf("Removing '%v'", path)
+
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("Unable to remove '%v': %v", path, err)
+		}
+	}
+
+	if err := writeFileAtomic(d.layoutPath(), []byte(fmt.Sprintf("%v\n", STORE_LAYOUT)), 0644); err != nil {
+		return fmt.Errorf("Unable to write store layout: %v", err)
+	}
+
+	return nil
+}
+
+// Put a hardlink (or copy) of a flat store binary at dst, leaving src in place
+func migrateBinary(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("Unable to create '%v': %v", filepath.Dir(dst), err)
+	}
+
+	// Already in place (eg. downloaded again by a newer dkenv, or an
+	// interrupted migration)
+	if _, err := os.Stat(dst); err == nil {
+		log.Debugf("'%v' already exists", dst)
+		return nil
+	}
+
+	log.Debugf("Linking '%v' to '%v'", src, dst)
+
+	err := replaceWith(dst, func(tmp string) error {
+		return linkOrCopy(src, tmp)
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to move '%v' to '%v': %v", src, dst, err)
+	}
+
+	return nil
+}
+
+// Repoint symlinks to flat binaries at their new location
+func (d *Dkenv) migrateLinks() error {
+	l, err := d.lock("link")
+	if err != nil {
+		return err
+	}
+	defer l.unlock()
+
+	sessions, _ := filepath.Glob(d.sessionsDir() + "/*/docker")
+	links := append([]string{d.dockerPath()}, sessions...)
+
+	for _, link := range links {
+		target, err := os.Readlink(link)
+		if err != nil {
+			continue
+		}
+
+		version, ok := d.flatVersion(target)
+		if !ok {
+			continue
+		}
+
+		log.Infof("Pointing '%v' at '%v'", link, d.legacyBinaryPath(version))
+
+		if err := replaceSymlink(d.legacyBinaryPath(version), link); err != nil {
+			return fmt.Errorf("Unable to update symlink '%v': %v", link, err)
+		}
+	}
+
+	return d.updateState(func(state *State) error {
+		if version, ok := d.flatVersion(state.ActiveLink); ok {
+			state.ActiveLink = d.legacyBinaryPath(version)
+		}
+
+		return nil
+	})
+}
+
+// Version of a binary in a flat (layout 1) store; leftovers such as
+// docker-1.9.1.partial don't count
+func (d *Dkenv) flatVersion(path string) (string, bool) {
+	if filepath.Dir(path) != filepath.Clean(d.DkenvDir) {
+		return "", false
+	}
+
+	base := filepath.Base(path)
+	if !strings.HasPrefix(base, "docker-") {
+		return "", false
+	}
+
+	version := strings.TrimPrefix(base, "docker-")
+
+	return version, versionRegex.MatchString(version)
+}
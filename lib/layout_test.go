@@ -0,0 +1,115 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateStore(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+
+	// Flat store, as left behind by older dkenv versions
+	for _, version := range []string{"1.8.3", "1.9.1"} {
+		if err := ioutil.WriteFile(tmpDkenvDir+"/docker-"+version, []byte("binary"), 0755); err != nil {
+			t.Fatalf("Unable to create fake docker binary: %v", err)
+		}
+	}
+
+	// Leftover from an interrupted download
+	assert.NoError(t, ioutil.WriteFile(tmpDkenvDir+"/docker-1.9.1.partial", []byte("bin"), 0644))
+
+	assert.NoError(t, os.Symlink(tmpDkenvDir+"/docker-1.9.1", d.dockerPath()))
+	assert.NoError(t, d.saveState(&State{ActiveLink: tmpDkenvDir + "/docker-1.9.1"}))
+
+	layout, err := d.storeLayout()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, layout)
+
+	assert.NoError(t, d.MigrateStore())
+
+	assert.True(t, d.isInstalled("1.8.3"))
+	assert.True(t, d.isInstalled("1.9.1"))
+	assertLink(t, d.dockerPath(), d.binaryPath("1.9.1"))
+
+	_, err = os.Stat(tmpDkenvDir + "/docker-1.8.3")
+	assert.True(t, os.IsNotExist(err))
+
+	// Not migrated, but left alone
+	_, err = os.Stat(tmpDkenvDir + "/docker-1.9.1.partial")
+	assert.NoError(t, err)
+
+	_, err = os.Stat(d.DkenvDir + "/" + VERSIONS_DIR + "/1.9.1.partial")
+	assert.True(t, os.IsNotExist(err))
+
+	// No false "changed outside of dkenv" warnings
+	changed, _, _ := d.linkChanged()
+	assert.False(t, changed)
+
+	installed, err := d.listInstalled()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1.8.3", "1.9.1"}, installed)
+
+	layout, err = d.storeLayout()
+	assert.NoError(t, err)
+	assert.Equal(t, STORE_LAYOUT, layout)
+
+	// Noop once migrated
+	assert.NoError(t, d.MigrateStore())
+
+	// Written by a newer dkenv
+	assert.NoError(t, ioutil.WriteFile(d.layoutPath(), []byte("99\n"), 0644))
+	assert.Error(t, d.MigrateStore())
+}
+
+func TestVersionFromPath(t *testing.T) {
+	d := New("/home/u/.dkenv", "/usr/local/bin")
+
+	version, ok := d.versionFromPath(d.binaryPath("1.9.1"))
+	assert.True(t, ok)
+	assert.Equal(t, "1.9.1", version)
+
+	_, ok = d.versionFromPath("/home/u/.dkenv/docker-1.9.1")
+	assert.False(t, ok)
+
+	_, ok = d.versionFromPath("/home/u/.dkenv/versions/1.9.1/plan9-mips/bin/docker")
+	assert.False(t, ok)
+
+	_, ok = d.versionFromPath("/usr/bin/docker")
+	assert.False(t, ok)
+}
+
+func TestMigrateStoreInterrupted(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+	flat := tmpDkenvDir + "/docker-1.9.1"
+
+	assert.NoError(t, ioutil.WriteFile(flat, []byte("binary"), 0755))
+	assert.NoError(t, os.Symlink(flat, d.dockerPath()))
+
+	// Stopped right after putting the binary in place: docker still works
+	assert.NoError(t, migrateBinary(flat, d.legacyBinaryPath("1.9.1")))
+
+	_, err := os.Stat(d.dockerPath())
+	assert.NoError(t, err)
+
+	// The next run picks up where it left off
+	assert.NoError(t, d.MigrateStore())
+	assertLink(t, d.dockerPath(), d.legacyBinaryPath("1.9.1"))
+
+	data, err := ioutil.ReadFile(d.dockerPath())
+	assert.NoError(t, err)
+	assert.Equal(t, "binary", string(data))
+
+	_, err = os.Stat(flat)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -3,10 +3,12 @@ package lib
 import (
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"math/rand"
+	"io"
 	"os"
-	"regexp"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 )
@@ -30,48 +32,74 @@ var (
 type Dkenv struct {
 	DkenvDir string
 	BinDir   string
-}
 
-func New(dkenvDir, binDir string) *Dkenv {
-	return &Dkenv{
-		DkenvDir: dkenvDir,
-		BinDir:   binDir,
-	}
-}
+	// Where disposable state (eg. shell session dirs) lives; defaults to
+	// DkenvDir
+	CacheDir string
 
-func (d *Dkenv) ListAction() error {
-	installed, err := d.listInstalled()
-	if err != nil {
-		return err
-	}
+	// Destination for output meant to be consumed by scripts/shells (as
+	// opposed to log messages)
+	Out io.Writer
 
-	if len(installed) == 0 {
-		log.Warning("No installed Docker binaries found!")
-		return nil
-	} else {
-		log.Infof("Found %v installed docker binaries", len(installed))
-		log.Info("") // blank line, for the pretty
-	}
+	// Command which caused the current run's changes; recorded in history
+	Trigger string
 
-	for i := 0; i < len(installed); i++ {
-		log.Infof("%v: %v", i+1, installed[i])
-	}
+	// How long to wait for other dkenv processes to release a lock
+	LockTimeout time.Duration
 
-	return nil
+	// Base URLs to download docker binaries from, tried in order; only used
+	// for releases before 17.03
+	Mirrors []string
+
+	// Release channel (see Channels) and the site serving it, for releases
+	// since 17.03; defaults to stable on DEFAULT_CHANNEL_URL
+	Channel    string
+	ChannelURL string
+
+	// HTTP(S) proxy for downloads; the usual proxy env vars apply if empty
+	Proxy string
+
+	// Version to use when neither a .docker-version nor a global version
+	// applies
+	DefaultVersion string
+
+	// Retry the final docker link step via sudo if BinDir isn't writable
+	Sudo bool
+
+	// How BinDir/docker is linked to the store (see LinkModes)
+	LinkMode string
 }
 
+func New(dkenvDir, binDir string) *Dkenv {
+	return &Dkenv{
+		DkenvDir:    dkenvDir,
+		BinDir:      binDir,
+		CacheDir:    dkenvDir,
+		Out:         os.Stdout,
+		LockTimeout: DEFAULT_LOCK_TIMEOUT,
+		Mirrors:     []string{DEFAULT_MIRROR},
+		LinkMode:    LINK_SYMLINK,
+	}
+}
+
+// Install (if needed) and switch to a docker client version, alias (see
+// AliasAction) or, if api is set, the client matching an API version
 func (d *Dkenv) FetchVersionAction(version string, api bool) error {
-	clientVersion := version
+	var clientVersion string
+	var err error
 
 	if api {
-		var err error
-
 		clientVersion, err = ApiToVersion(version)
 		if err != nil {
 			return err
 		}
 
 		log.Infof("Found client '%v' for API version '%v'", clientVersion, version)
+	} else {
+		clientVersion, err = d.resolveAlias(version)
+		if err != nil {
+			return err
+		}
 	}
 
 	if !d.isInstalled(clientVersion) {
@@ -85,6 +113,31 @@ func (d *Dkenv) FetchVersionAction(version string, api bool) error {
 		log.Infof("Docker version %v already installed!", clientVersion)
 	}
 
+	// In shim mode the shim picks the version on every invocation, so only
+	// the default needs to change
+	if d.LinkMode == LINK_SHIM && !d.shimInstalled() {
+		if err := d.InstallShimAction(); err != nil {
+			return err
+		}
+	}
+
+	if d.shimInstalled() {
+		log.Infof("Docker shim installed in %v - setting global version instead of symlinking", d.BinDir)
+		d.touchVersion(clientVersion)
+
+		from, _ := readVersionFile(d.globalVersionPath())
+
+		if err := d.GlobalAction(clientVersion); err != nil {
+			return err
+		}
+
+		if from == clientVersion {
+			return nil
+		}
+
+		return d.recordSwitch(from, clientVersion)
+	}
+
 	// Update symlink
 	if err := d.UpdateSymlink(clientVersion); err != nil {
 		return err
@@ -93,111 +146,211 @@ func (d *Dkenv) FetchVersionAction(version string, api bool) error {
 	return nil
 }
 
-// Return a list of found docker binaries
+// Return the versions found in the store
 func (d *Dkenv) listInstalled() ([]string, error) {
-	fileList, err := ioutil.ReadDir(d.DkenvDir)
+	if _, err := os.Stat(d.DkenvDir); err != nil {
+		return nil, err
+	}
+
+	binaries, err := filepath.Glob(d.binaryPath("*"))
 	if err != nil {
 		return nil, err
 	}
 
 	found := make([]string, 0)
 
-	for _, filename := range fileList {
-		match, _ := regexp.MatchString(`docker-.+`, filename.Name())
-		if match {
-			found = append(found, filename.Name())
+	for _, path := range binaries {
+		version, ok := d.versionFromPath(path)
+		if !ok || !versionRegex.MatchString(version) {
+			continue
 		}
+
+		// Skip anything that isn't a complete binary, eg. a leftover
+		// directory from an interrupted install
+		if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+
+		found = append(found, version)
 	}
 
 	return found, nil
 }
 
 func (d *Dkenv) isInstalled(version string) bool {
-	if _, err := os.Stat(d.DkenvDir + "/docker-" + version); err == nil {
+	if _, err := os.Stat(d.binaryPath(version)); err == nil {
 		return true
 	}
 
 	return false
 }
 
-// Symlink docker to a dkenv docker binary
+// Location of the dkenv managed docker binary for a version (see MigrateStore
+// for the layout)
+func (d *Dkenv) binaryPath(version string) string {
+	return d.DkenvDir + "/" + VERSIONS_DIR + "/" + version + "/" + platform() + "/bin/docker"
+}
+
+// Inverse of binaryPath; reports false if path is not a dkenv managed binary
+func (d *Dkenv) versionFromPath(path string) (string, bool) {
+	rel, err := filepath.Rel(filepath.Join(d.DkenvDir, VERSIONS_DIR), path)
+	if err != nil {
+		return "", false
+	}
+
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) != 4 || parts[0] == ".." || parts[1] != platform() || parts[2] != "bin" || parts[3] != "docker" {
+		return "", false
+	}
+
+	return parts[0], true
+}
+
+// Link docker to a dkenv docker binary, using the configured link mode (see
+// LinkModes)
 //
-// - If destination exists AND is *not* a symlink:
-//     - backup destination file
-//     - create symlink
-// - If destination exists AND *is* a symlink:
-//     - check if the symlink already matches destination (noop if it does)
-//     - remove and overwrite if symlink is incorrect
+// - If destination exists AND is managed by dkenv (a symlink into the store,
+//   or a hardlink/copy of an installed binary):
+//     - noop if it already is what the link mode would create
+//     - replace it otherwise
+// - If destination exists AND is any other symlink:
+//     - replace it
+// - If destination exists AND is any other file:
+//     - backup destination file (see 'dkenv backups' and 'dkenv restore')
+//     - create link
 // - If destination does not exist:
-//     - create symlink
+//     - create link
+//
+// The new link is always created under a temporary name and renamed over
+// the destination, so there is never a moment where the destination is
+// missing (and a failure leaves the old destination untouched).
 //
-// TODO This will be a pain to test - refactor when possible.
+// Every actual switch is recorded in the history log (see 'dkenv history').
 func (d *Dkenv) UpdateSymlink(version string) error {
-	src := d.DkenvDir + "/docker-" + version
-	dst := d.BinDir + "/docker"
+	src := d.binaryPath(version)
 
 	// Verify that the src file actually exists
 	if _, err := os.Stat(src); err != nil {
 		return fmt.Errorf("Unable to lookup source binary '%v': %v", src, err)
 	}
 
-	// Check if file exists; bail if real error
-	_, err := os.Stat(dst)
-	if err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("Problems verifying existing docker symlink: %v", err)
+	// Version we're switching away from, for the history log
+	var from string
+
+	if info, err := d.inspectLink(d.dockerPath()); err == nil {
+		from = info.Version
 	}
 
-	// File does not exist, let's create it
-	if err != nil && os.IsNotExist(err) {
-		log.Infof("Creating symlink for '%v' -> '%v'", dst, src)
+	if err := d.linkDocker(src, d.versionLinkMode()); err != nil {
+		return err
+	}
 
-		if err := os.Symlink(src, dst); err != nil {
-			return fmt.Errorf("Unable to create new docker symlink: %v", err)
-		}
+	d.touchVersion(version)
 
+	if from == version {
 		return nil
 	}
 
-	// File exists; check if symlink
-	fi, err := os.Lstat(dst)
+	return d.recordSwitch(from, version)
+}
+
+// Point BinDir/docker at src, backing up whatever unmanaged non-symlink file
+// is there
+func (d *Dkenv) linkDocker(src, mode string) error {
+	l, err := d.lock("link")
 	if err != nil {
-		return fmt.Errorf("Unable to stat existing docker file '%v': %v", dst, err)
+		return err
 	}
+	defer l.unlock()
 
-	if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
-		tmpDst, err := os.Readlink(dst)
-		if err != nil {
-			return fmt.Errorf("Unable to lookup link info for existing docker symlink: %v", err)
-		}
+	dst := d.dockerPath()
 
-		if tmpDst == src {
-			log.Infof("'%v' already pointing to '%v' - nothing to do!", dst, src)
-			return nil
-		}
+	// Check if file exists; bail if real error
+	current, err := d.inspectLink(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Problems verifying existing docker link: %v", err)
+	}
 
-		if err := os.Remove(dst); err != nil {
-			return fmt.Errorf("Unable to remove old symlink: %v", err)
+	switch {
+	case err != nil:
+		// File does not exist, nothing to check or backup
+	case current.matches(src, mode):
+		log.Infof("'%v' already is a %v to '%v' - nothing to do!", dst, mode, src)
+		return nil
+	case current.Mode == "" && current.Target == "":
+		if _, err := d.backupFile(dst); err != nil {
+			return err
 		}
-	} else {
-		backupName := dst + fmt.Sprintf(".%v.dkenv", rand.Intn(9999))
+	}
 
-		log.Infof("Backing up existing docker file %v to %v", dst, backupName)
+	// Create/overwrite old link
+	log.Infof("Creating %v for '%v' -> '%v'", mode, dst, src)
 
-		if err := os.Rename(dst, backupName); err != nil {
-			return fmt.Errorf("Unable to backup existing docker file: %v", err)
+	err = placeLink(src, dst, mode)
+	if err != nil && os.IsPermission(err) && d.Sudo {
+		if mode != LINK_SYMLINK && mode != LINK_SHIM {
+			return fmt.Errorf("Unable to create new docker %v: %v (--sudo only supports the symlink and shim link modes)", mode, err)
 		}
+
+		err = sudoReplaceSymlink(src, dst)
 	}
 
-	// Create/overwrite old symlink
-	log.Infof("Creating symlink for '%v' -> '%v'", dst, src)
+	if err != nil {
+		return fmt.Errorf("Unable to create new docker %v: %v", mode, err)
+	}
+
+	d.warnIfNotOnPath()
+
+	return d.updateState(func(state *State) error {
+		state.ActiveLink = src
+		return nil
+	})
+}
+
+// Location of the docker file managed by dkenv
+func (d *Dkenv) dockerPath() string {
+	return d.BinDir + "/docker"
+}
+
+// Atomically point dst at src by creating a temporary symlink next to dst and
+// rename(2)'ing it into place
+func replaceSymlink(src, dst string) error {
+	tmp := tempLinkPath(dst)
+
+	// Leftover from a previous, interrupted run
+	os.Remove(tmp)
 
-	if err := os.Symlink(src, dst); err != nil {
-		return fmt.Errorf("Unable to create new docker symlink: %v", err)
+	if err := os.Symlink(src, tmp); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
 	}
 
 	return nil
 }
 
+// Temporary name for a new symlink which will be renamed over dst
+func tempLinkPath(dst string) string {
+	return filepath.Join(filepath.Dir(dst), fmt.Sprintf(".%v.dkenv-%v.tmp", filepath.Base(dst), os.Getpid()))
+}
+
+// PID of the process which created a temporary symlink (see tempLinkPath)
+func tempLinkPid(path string) (int, bool) {
+	name := strings.TrimSuffix(filepath.Base(path), ".tmp")
+
+	i := strings.LastIndex(name, ".dkenv-")
+	if i < 0 {
+		return 0, false
+	}
+
+	pid, err := strconv.Atoi(name[i+len(".dkenv-"):])
+
+	return pid, err == nil
+}
+
 func ApiToVersion(version string) (string, error) {
 	if val, ok := apiVersions[version]; ok {
 		return val, nil
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -19,7 +20,7 @@ var (
 func init() {
 	tmpBinDir, err := ioutil.TempDir("", "tmp_dkenv_bin")
 	if err != nil {
-		fmt.Println("Unable to create tmp dir for testing: %v", err)
+		fmt.Printf("Unable to create tmp dir for testing: %v\n", err)
 		os.Exit(1)
 	}
 
@@ -28,7 +29,7 @@ func init() {
 
 	tmpDkenvDir, err := ioutil.TempDir("", "tmp_dkenv_dir")
 	if err != nil {
-		fmt.Println("Unable to create tmp dir for testing: %v", err)
+		fmt.Printf("Unable to create tmp dir for testing: %v\n", err)
 		os.Exit(1)
 	}
 
@@ -44,10 +45,6 @@ func TestNew(t *testing.T) {
 	assert.Equal(t, testBinDir, c.BinDir)
 }
 
-func TestListAction(t *testing.T) {
-
-}
-
 func TestListInstalled(t *testing.T) {
 
 }
@@ -65,12 +62,85 @@ func TestApiToVersion(t *testing.T) {
 }
 
 func TestUpdateSymlink(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+	dst := tmpBinDir + "/docker"
+
+	for _, version := range []string{"1.8.3", "1.9.1"} {
+		installFake(t, tmpDkenvDir, version)
+	}
+
+	// Missing source binary
+	assert.Error(t, d.UpdateSymlink("1.0.0"))
+
+	// Fresh symlink
+	assert.NoError(t, d.UpdateSymlink("1.8.3"))
+	assertLink(t, dst, d.binaryPath("1.8.3"))
+
+	// Noop
+	assert.NoError(t, d.UpdateSymlink("1.8.3"))
+	assertLink(t, dst, d.binaryPath("1.8.3"))
+
+	// Replace existing symlink
+	assert.NoError(t, d.UpdateSymlink("1.9.1"))
+	assertLink(t, dst, d.binaryPath("1.9.1"))
+
+	// No temporary links left behind
+	files, _ := ioutil.ReadDir(tmpBinDir)
+	assert.Len(t, files, 1)
+
+	// Existing regular file gets backed up
+	os.Remove(dst)
+	if err := ioutil.WriteFile(dst, []byte("original"), 0755); err != nil {
+		t.Fatalf("Unable to create fake docker binary: %v", err)
+	}
+
+	assert.NoError(t, d.UpdateSymlink("1.8.3"))
+	assertLink(t, dst, d.binaryPath("1.8.3"))
+
+	backups, _ := filepath.Glob(tmpBinDir + "/docker.*.dkenv")
+	assert.Len(t, backups, 1)
+}
+
+func makeTestDirs(t *testing.T) (string, string) {
+	tmpDkenvDir, err := ioutil.TempDir("", "tmp_dkenv_dir")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir for testing: %v", err)
+	}
+
+	tmpBinDir, err := ioutil.TempDir("", "tmp_dkenv_bin")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir for testing: %v", err)
+	}
+
+	return tmpDkenvDir, tmpBinDir
+}
+
+// Put a fake docker binary for version in the store
+func installFake(t *testing.T, dkenvDir, version string) {
+	path := New(dkenvDir, "").binaryPath(version)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Unable to create store dir: %v", err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("binary"), 0755); err != nil {
+		t.Fatalf("Unable to create fake docker binary: %v", err)
+	}
+}
 
+func assertLink(t *testing.T, link, target string) {
+	dst, err := os.Readlink(link)
+	assert.NoError(t, err)
+	assert.Equal(t, target, dst)
 }
 
 func cleanUp(tmpDir string) {
 	if err := os.RemoveAll(tmpDir); err != nil {
-		fmt.Println("Unable to clean up tmp dir: %v", err)
+		fmt.Printf("Unable to clean up tmp dir: %v\n", err)
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,244 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	LINK_SYMLINK  = "symlink"
+	LINK_RELATIVE = "relative-symlink"
+	LINK_HARDLINK = "hardlink"
+	LINK_COPY     = "copy"
+	LINK_SHIM     = "shim"
+)
+
+var (
+	LinkModes = []string{LINK_SYMLINK, LINK_RELATIVE, LINK_HARDLINK, LINK_COPY, LINK_SHIM}
+)
+
+// What a docker file in BinDir is, as far as dkenv is concerned
+type linkInfo struct {
+	// One of the LINK_* constants; empty if dkenv doesn't manage the file
+	Mode string
+
+	// Installed version the file provides; empty for the shim and for
+	// unmanaged files
+	Version string
+
+	// Absolute path of the store binary (or dkenv executable, for the shim)
+	// the file links to or is a copy of
+	Source string
+
+	// Raw symlink target, if the file is a symlink
+	Target string
+}
+
+// Describe the file for messages
+func (l *linkInfo) String() string {
+	switch {
+	case l.Target != "":
+		return l.Target
+	case l.Mode == LINK_HARDLINK:
+		return "hardlink of " + l.Source
+	case l.Mode == LINK_COPY:
+		return "copy of " + l.Source
+	}
+
+	return "<unmanaged file>"
+}
+
+// Whether a symlink target is a dkenv executable, ie. the shim. That needn't
+// be the running executable: dkenv may have been upgraded, reinstalled or
+// moved since the shim was installed.
+func isShimSource(source string) bool {
+	if exe, err := shimTarget(); err == nil && source == exe {
+		return true
+	}
+
+	names := []string{filepath.Base(source)}
+
+	if resolved, err := filepath.EvalSymlinks(source); err == nil {
+		names = append(names, filepath.Base(resolved))
+	}
+
+	for _, name := range names {
+		name = strings.TrimSuffix(name, ".exe")
+
+		if name == "dkenv" || strings.HasPrefix(name, "dkenv-") || strings.HasPrefix(name, "dkenv_") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Figure out how (and whether) path is linked to the store
+func (d *Dkenv) inspectLink(path string) (*linkInfo, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+
+		info := &linkInfo{Mode: LINK_SYMLINK, Target: target, Source: target}
+
+		if !filepath.IsAbs(target) {
+			info.Mode = LINK_RELATIVE
+			info.Source = filepath.Join(filepath.Dir(path), target)
+		}
+
+		if isShimSource(info.Source) {
+			info.Mode = LINK_SHIM
+			return info, nil
+		}
+
+		version, ok := d.versionFromPath(info.Source)
+		if !ok {
+			info.Mode = ""
+		}
+
+		info.Version = version
+
+		return info, nil
+	}
+
+	installed, err := d.listInstalled()
+	if err != nil {
+		return &linkInfo{}, nil
+	}
+
+	// Hardlinks are cheap to spot; copies need a checksum
+	for _, version := range installed {
+		if bfi, err := os.Stat(d.binaryPath(version)); err == nil && os.SameFile(fi, bfi) {
+			return &linkInfo{Mode: LINK_HARDLINK, Version: version, Source: d.binaryPath(version)}, nil
+		}
+	}
+
+	var checksum string
+
+	for _, version := range installed {
+		meta, err := d.loadMeta(version)
+		if err != nil || meta.Size != fi.Size() {
+			continue
+		}
+
+		if checksum == "" {
+			if checksum, err = sha256File(path); err != nil {
+				return nil, err
+			}
+		}
+
+		if checksum == meta.SHA256 {
+			return &linkInfo{Mode: LINK_COPY, Version: version, Source: d.binaryPath(version)}, nil
+		}
+	}
+
+	return &linkInfo{}, nil
+}
+
+// Check whether a file inspectLink described is what linking src with mode
+// would produce
+func (l *linkInfo) matches(src, mode string) bool {
+	return l.Mode == mode && l.Source == src
+}
+
+// Atomically replace dst with a link to (or copy of) src, as mode says
+func placeLink(src, dst, mode string) error {
+	switch mode {
+	case LINK_RELATIVE:
+		rel, err := filepath.Rel(filepath.Dir(dst), src)
+		if err != nil {
+			return err
+		}
+
+		return replaceSymlink(rel, dst)
+	case LINK_HARDLINK:
+		return replaceWith(dst, func(tmp string) error {
+			if err := os.Link(src, tmp); err != nil {
+				return fmt.Errorf("%v (hardlinks don't work across filesystems - try the copy link mode)", err)
+			}
+
+			return nil
+		})
+	case LINK_COPY:
+		return replaceWith(dst, func(tmp string) error {
+			return copyFile(src, tmp, 0755)
+		})
+	}
+
+	return replaceSymlink(src, dst)
+}
+
+// Create a file next to dst using create, then rename(2) it over dst
+func replaceWith(dst string, create func(tmp string) error) error {
+	tmp := tempLinkPath(dst)
+
+	// Leftover from a previous, interrupted run
+	os.Remove(tmp)
+
+	if err := create(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// Link mode to use for docker versions; the shim mode links versions like
+// the default mode once the shim is removed
+func (d *Dkenv) versionLinkMode() string {
+	if d.LinkMode == "" || d.LinkMode == LINK_SHIM {
+		return LINK_SYMLINK
+	}
+
+	return d.LinkMode
+}
+
+func isLinkMode(mode string) bool {
+	for _, m := range LinkModes {
+		if m == mode {
+			return true
+		}
+	}
+
+	return false
+}
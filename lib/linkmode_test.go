@@ -0,0 +1,101 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkModes(t *testing.T) {
+	for _, mode := range []string{LINK_RELATIVE, LINK_HARDLINK, LINK_COPY} {
+		tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+		defer cleanUp(tmpDkenvDir)
+		defer cleanUp(tmpBinDir)
+
+		d := New(tmpDkenvDir, tmpBinDir)
+		d.LinkMode = mode
+
+		// Copies are told apart by checksum, so the fakes must differ
+		for _, version := range []string{"1.8.3", "1.9.1"} {
+			installFake(t, tmpDkenvDir, version)
+
+			if err := ioutil.WriteFile(d.binaryPath(version), []byte(version), 0755); err != nil {
+				t.Fatalf("Unable to create fake docker binary: %v", err)
+			}
+		}
+
+		assert.NoError(t, d.UpdateSymlink("1.8.3"), mode)
+
+		info, err := d.inspectLink(d.dockerPath())
+		assert.NoError(t, err, mode)
+		assert.Equal(t, mode, info.Mode)
+		assert.Equal(t, "1.8.3", info.Version, mode)
+		assert.Equal(t, d.binaryPath("1.8.3"), info.Source, mode)
+
+		// Noop
+		assert.NoError(t, d.UpdateSymlink("1.8.3"), mode)
+
+		// Switching replaces the managed file without backing it up
+		assert.NoError(t, d.UpdateSymlink("1.9.1"), mode)
+
+		info, err = d.inspectLink(d.dockerPath())
+		assert.NoError(t, err, mode)
+		assert.Equal(t, "1.9.1", info.Version, mode)
+
+		files, _ := ioutil.ReadDir(tmpBinDir)
+		assert.Len(t, files, 1, mode)
+
+		changed, _, _ := d.linkChanged()
+		assert.False(t, changed, mode)
+
+		active, err := d.ActiveVersion(tmpDkenvDir)
+		assert.NoError(t, err, mode)
+		assert.Equal(t, "1.9.1", active.Version, mode)
+		assert.Equal(t, mode, active.Source)
+	}
+}
+
+func TestInspectLink(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+	dst := d.dockerPath()
+
+	installFake(t, tmpDkenvDir, "1.8.3")
+
+	// Missing
+	_, err := d.inspectLink(dst)
+	assert.True(t, os.IsNotExist(err))
+
+	// Symlink out of the store
+	os.Symlink("/usr/bin/true", dst)
+
+	info, err := d.inspectLink(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, "", info.Mode)
+	assert.Equal(t, "/usr/bin/true", info.Target)
+
+	// Relative symlink into the store
+	os.Remove(dst)
+	rel, _ := filepath.Rel(tmpBinDir, d.binaryPath("1.8.3"))
+	os.Symlink(rel, dst)
+
+	info, err = d.inspectLink(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, LINK_RELATIVE, info.Mode)
+	assert.Equal(t, "1.8.3", info.Version)
+
+	// Unrelated regular file
+	os.Remove(dst)
+	ioutil.WriteFile(dst, []byte("something else"), 0755)
+
+	info, err = d.inspectLink(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, "", info.Mode)
+	assert.Equal(t, "<unmanaged file>", info.String())
+}
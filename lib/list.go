@@ -0,0 +1,247 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/alecthomas/template"
+)
+
+const (
+	OUTPUT_TABLE    = "table"
+	OUTPUT_JSON     = "json"
+	OUTPUT_YAML     = "yaml"
+	OUTPUT_TEMPLATE = "template"
+)
+
+// An installed version, as reported by 'dkenv list'
+type ListEntry struct {
+	Version     string     `json:"version"`
+	API         string     `json:"api_version"`
+	Aliases     []string   `json:"aliases,omitempty"`
+	Active      bool       `json:"active"`
+	ActiveVia   string     `json:"active_via,omitempty"` // How the active version was chosen (see Resolution.Source)
+	Path        string     `json:"path"`
+	Size        int64      `json:"size"`
+	InstalledAt *time.Time `json:"installed_at"` // nil if unknown
+	LastUsed    *time.Time `json:"last_used"`    // nil if never used
+}
+
+// Print installed versions, oldest first, along with the aliases pointing at
+// them, in one of the OUTPUT_* formats; the template format is given as
+// "template=<text/template>" and executed once per version
+func (d *Dkenv) ListAction(format, dir string) error {
+	entries, err := d.listEntries(dir)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case format == "" || format == OUTPUT_TABLE:
+		if len(entries) == 0 {
+			log.Warning("No installed Docker binaries found!")
+			return nil
+		}
+
+		return writeListTable(d.Out, entries)
+	case format == OUTPUT_JSON:
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(d.Out, string(data))
+
+		return nil
+	case format == OUTPUT_YAML:
+		writeListYAML(d.Out, entries)
+		return nil
+	case strings.HasPrefix(format, OUTPUT_TEMPLATE+"="):
+		return writeListTemplate(d.Out, strings.TrimPrefix(format, OUTPUT_TEMPLATE+"="), entries)
+	}
+
+	return fmt.Errorf("Unknown output format '%v' (use table, json, yaml or template=<template>)", format)
+}
+
+// Gather everything 'dkenv list' shows about installed versions, sorted by
+// version
+func (d *Dkenv) listEntries(dir string) ([]*ListEntry, error) {
+	installed, err := d.listInstalled()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	sort.Slice(installed, func(i, j int) bool {
+		return compareVersions(installed[i], installed[j]) < 0
+	})
+
+	// Nothing linked (yet) is fine, nothing is active then
+	active, _ := d.ActiveVersion(dir)
+
+	// 'list' shouldn't need the network
+	aliases, err := d.aliases(true)
+	if err != nil {
+		return nil, err
+	}
+
+	aliasNames := make([]string, 0, len(aliases))
+	for name := range aliases {
+		aliasNames = append(aliasNames, name)
+	}
+
+	sort.Strings(aliasNames)
+
+	entries := make([]*ListEntry, 0, len(installed))
+
+	for _, version := range installed {
+		e := &ListEntry{Version: version, Path: d.binaryPath(version)}
+		e.API, _ = VersionToApi(version)
+
+		for _, name := range aliasNames {
+			if aliases[name] == version {
+				e.Aliases = append(e.Aliases, name)
+			}
+		}
+
+		if meta, err := d.loadMeta(version); err == nil {
+			e.Size = meta.Size
+			e.InstalledAt = optionalTime(meta.InstalledAt)
+			e.LastUsed = optionalTime(meta.LastUsed)
+		} else {
+			log.Debugf("No metadata for docker %v: %v", version, err)
+		}
+
+		if active != nil && active.Version == version {
+			e.Active = true
+			e.ActiveVia = active.Source
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+func writeListTable(out io.Writer, entries []*ListEntry) error {
+	var buf bytes.Buffer
+
+	w := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(w, "\tVERSION\tALIASES\tAPI\tSIZE\tINSTALLED\tLAST USED\t")
+
+	for _, e := range entries {
+		marker := ""
+		if e.Active {
+			marker = "*"
+		}
+
+		api := e.API
+		if api == "" {
+			api = "-"
+		}
+
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t", marker, e.Version, strings.Join(e.Aliases, ","), api, humanBytes(e.Size),
+			formatListTime(e.InstalledAt), formatListTime(e.LastUsed))
+
+		if e.Active {
+			fmt.Fprintf(w, "(active, %v)", e.ActiveVia)
+		}
+
+		fmt.Fprintln(w)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	// Padding before the (usually empty) last column
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		fmt.Fprintln(out, strings.TrimRight(line, " "))
+	}
+
+	return nil
+}
+
+// Zero times mean "unknown" or "never", which JSON and YAML show as null
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+func formatListTime(t *time.Time) string {
+	if t == nil {
+		return "never"
+	}
+
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// Entries as a YAML sequence; written by hand as dkenv doesn't vendor a YAML
+// library (see cli.Config for the reading side)
+func writeListYAML(out io.Writer, entries []*ListEntry) {
+	if len(entries) == 0 {
+		fmt.Fprintln(out, "[]")
+		return
+	}
+
+	for _, e := range entries {
+		fmt.Fprintf(out, "- version: %v\n", strconv.Quote(e.Version))
+		fmt.Fprintf(out, "  api_version: %v\n", strconv.Quote(e.API))
+
+		if len(e.Aliases) > 0 {
+			quoted := make([]string, len(e.Aliases))
+			for i, name := range e.Aliases {
+				quoted[i] = strconv.Quote(name)
+			}
+
+			fmt.Fprintf(out, "  aliases: [%v]\n", strings.Join(quoted, ", "))
+		}
+		fmt.Fprintf(out, "  active: %v\n", e.Active)
+
+		if e.ActiveVia != "" {
+			fmt.Fprintf(out, "  active_via: %v\n", e.ActiveVia)
+		}
+
+		fmt.Fprintf(out, "  path: %v\n", strconv.Quote(e.Path))
+		fmt.Fprintf(out, "  size: %v\n", e.Size)
+		fmt.Fprintf(out, "  installed_at: %v\n", formatYAMLTime(e.InstalledAt))
+		fmt.Fprintf(out, "  last_used: %v\n", formatYAMLTime(e.LastUsed))
+	}
+}
+
+func formatYAMLTime(t *time.Time) string {
+	if t == nil {
+		return "null"
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+func writeListTemplate(out io.Writer, text string, entries []*ListEntry) error {
+	tmpl, err := template.New("list").Parse(text)
+	if err != nil {
+		return fmt.Errorf("Invalid list template: %v", err)
+	}
+
+	for _, e := range entries {
+		if err := tmpl.Execute(out, e); err != nil {
+			return fmt.Errorf("Unable to render list template: %v", err)
+		}
+
+		fmt.Fprintln(out)
+	}
+
+	return nil
+}
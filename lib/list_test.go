@@ -0,0 +1,63 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListAction(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	var out bytes.Buffer
+
+	d := New(tmpDkenvDir, tmpBinDir)
+	d.Out = &out
+
+	for _, version := range []string{"1.10.3", "1.8.3", "1.9.1"} {
+		installFake(t, tmpDkenvDir, version)
+	}
+
+	// Not a complete binary
+	os.MkdirAll(d.binaryPath("1.7.1"), 0755)
+
+	// Not a version
+	installFake(t, tmpDkenvDir, "1.9.1.partial")
+
+	assert.NoError(t, d.UpdateSymlink("1.9.1"))
+
+	assert.NoError(t, d.ListAction("template={{.Version}} {{.API}} {{.Active}}", tmpDkenvDir))
+	assert.Equal(t, "1.8.3 1.20 false\n1.9.1 1.21 true\n1.10.3 1.22 false\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, d.ListAction(OUTPUT_JSON, tmpDkenvDir))
+
+	var entries []*ListEntry
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &entries))
+	assert.Len(t, entries, 3)
+	assert.Equal(t, LINK_SYMLINK, entries[1].ActiveVia)
+	assert.Equal(t, int64(len("binary")), entries[1].Size)
+	assert.Nil(t, entries[0].LastUsed)
+	assert.Contains(t, out.String(), `"last_used": null`)
+
+	out.Reset()
+	assert.NoError(t, d.ListAction(OUTPUT_TABLE, tmpDkenvDir))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[2], "*  1.9.1"), lines[2])
+
+	out.Reset()
+	assert.NoError(t, d.ListAction(OUTPUT_YAML, tmpDkenvDir))
+	assert.True(t, strings.HasPrefix(out.String(), "- version: \"1.8.3\"\n"))
+	assert.Contains(t, out.String(), "  last_used: null\n")
+
+	assert.Error(t, d.ListAction("xml", tmpDkenvDir))
+	assert.Error(t, d.ListAction("template={{.Nope", tmpDkenvDir))
+}
@@ -0,0 +1,101 @@
+package lib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	LOCK_DIR             = "locks"
+	DEFAULT_LOCK_TIMEOUT = time.Minute
+	LOCK_RETRY_INTERVAL  = 100 * time.Millisecond
+)
+
+// Advisory, cross-process lock backed by a file in DkenvDir/locks. The file
+// contains the PID of the holder, for error messages.
+type fileLock struct {
+	name string
+	file *os.File
+}
+
+// Acquire the named lock, waiting up to LockTimeout for other dkenv processes
+// to release it
+func (d *Dkenv) lock(name string) (*fileLock, error) {
+	dir := d.DkenvDir + "/" + LOCK_DIR
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("Unable to create lock dir: %v", err)
+	}
+
+	f, err := os.OpenFile(dir+"/"+name+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open lock file for '%v': %v", name, err)
+	}
+
+	deadline := time.Now().Add(d.LockTimeout)
+	waiting := false
+
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("Unable to acquire lock '%v': %v", name, err)
+		}
+
+		if locked {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			holder := lockHolder(f)
+			f.Close()
+
+			return nil, fmt.Errorf("Timed out after %v waiting for lock '%v' (held by %v)", d.LockTimeout, name, holder)
+		}
+
+		if !waiting {
+			log.Infof("Waiting for lock '%v' (held by %v)...", name, lockHolder(f))
+			waiting = true
+		}
+
+		time.Sleep(LOCK_RETRY_INTERVAL)
+	}
+
+	// Record ourselves as the holder
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	return &fileLock{name: name, file: f}, nil
+}
+
+func (l *fileLock) unlock() {
+	l.file.Truncate(0)
+
+	if err := unlockFile(l.file); err != nil {
+		log.Debugf("Unable to release lock '%v': %v", l.name, err)
+	}
+
+	l.file.Close()
+}
+
+// Describe the process holding a lock file
+func lockHolder(f *os.File) string {
+	data, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return "another dkenv process"
+	}
+
+	pid := strings.TrimSpace(string(data))
+	if pid == "" {
+		return "another dkenv process"
+	}
+
+	return "PID " + pid
+}
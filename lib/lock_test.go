@@ -0,0 +1,39 @@
+package lib
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLock(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+	d.LockTimeout = 200 * time.Millisecond
+
+	l1, err := d.lock("test")
+	assert.NoError(t, err)
+
+	// Held; times out naming the holder
+	_, err = d.lock("test")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "held by PID "+strconv.Itoa(os.Getpid()))
+
+	// Different locks don't interfere
+	l2, err := d.lock("other")
+	assert.NoError(t, err)
+	l2.unlock()
+
+	// Released locks can be taken again
+	l1.unlock()
+
+	l3, err := d.lock("test")
+	assert.NoError(t, err)
+	l3.unlock()
+}
@@ -0,0 +1,30 @@
+//go:build windows
+// +build windows
+
+package lib
+
+import (
+	"os"
+)
+
+// flock(2) is not available on Windows; locking is a noop there
+func tryLockFile(f *os.File) (bool, error) {
+	return true, nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
+
+// Report whether a process is still running; FindProcess fails for processes
+// which don't exist on Windows
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	p.Release()
+
+	return true
+}
@@ -0,0 +1,276 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	LOCK_FILE = "dkenv.lock"
+
+	// Bump when Lockfile changes incompatibly
+	LOCKFILE_VERSION = 1
+)
+
+// Docker versions a project needs, with their digests; meant to be committed
+// alongside .docker-version
+type Lockfile struct {
+	LockfileVersion int          `json:"lockfile_version"`
+	Docker          []*LockEntry `json:"docker"`
+}
+
+// A docker version for one OS/arch; a version appears once per platform it
+// was locked on
+type LockEntry struct {
+	Version string `json:"version"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	SHA256  string `json:"sha256"`
+	URL     string `json:"url,omitempty"`
+	Channel string `json:"channel,omitempty"` // See Channels; empty for releases before 17.03
+}
+
+// Install the versions in the nearest dkenv.lock and verify their digests;
+// fails if a version has no digest for this platform or an installed binary
+// doesn't match the lockfile
+func (d *Dkenv) SyncAction(dir string) error {
+	filename, err := findLockfile(dir)
+	if err != nil {
+		return err
+	}
+
+	lockfile, err := readLockfile(filename)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+
+	for _, version := range lockfile.versions() {
+		entry := lockfile.find(version, runtime.GOOS, runtime.GOARCH)
+		if entry == nil {
+			log.Errorf("%v has no digest of docker %v for %v - run 'dkenv lock' on this platform", filename, version, platform())
+			failed++
+
+			continue
+		}
+
+		if err := d.syncVersion(entry); err != nil {
+			log.Errorf("%v", err)
+			failed++
+
+			continue
+		}
+
+		log.Infof("Docker %v matches %v", version, filename)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%v locked docker version(s) could not be synced", failed)
+	}
+
+	return nil
+}
+
+// Install a locked version if needed, from the URL and channel it was locked
+// from, then verify its digest
+func (d *Dkenv) syncVersion(entry *LockEntry) error {
+	if !d.isInstalled(entry.Version) {
+		log.Infof("Docker version %v not found - attempting to download...", entry.Version)
+
+		if err := d.download(entry.Version, entry); err != nil {
+			return err
+		}
+	}
+
+	checksum, err := sha256File(d.binaryPath(entry.Version))
+	if err != nil {
+		return err
+	}
+
+	if checksum == entry.SHA256 {
+		return nil
+	}
+
+	return fmt.Errorf("Installed docker %v has drifted from the lockfile (sha256 %v, expected %v) - reinstall it with 'dkenv uninstall %v' and 'dkenv sync'",
+		entry.Version, checksum, entry.SHA256, entry.Version)
+}
+
+// (Re)write the nearest dkenv.lock (or a new one in dir) from the installed
+// binaries. Without versions, the ones already in the lockfile are relocked,
+// or the version resolved for dir if there is no lockfile yet. Entries for
+// other platforms are kept.
+func (d *Dkenv) LockAction(versions []string, dir string) error {
+	filename, err := findLockfile(dir)
+	if err != nil {
+		filename = filepath.Join(dir, LOCK_FILE)
+	}
+
+	previous := &Lockfile{}
+
+	if _, err := os.Stat(filename); err == nil {
+		if previous, err = readLockfile(filename); err != nil {
+			return err
+		}
+	}
+
+	if len(versions) == 0 {
+		versions = previous.versions()
+	}
+
+	if len(versions) == 0 {
+		res, err := d.ResolveVersion(dir)
+		if err != nil {
+			return fmt.Errorf("No versions to lock - pass them explicitly or pin one with 'dkenv local' (%v)", err)
+		}
+
+		versions = []string{res.Version}
+	}
+
+	lockfile := &Lockfile{LockfileVersion: LOCKFILE_VERSION, Docker: make([]*LockEntry, 0)}
+
+	for _, name := range versions {
+		version, err := d.resolveAlias(name)
+		if err != nil {
+			return err
+		}
+
+		if !d.isInstalled(version) {
+			return fmt.Errorf("Docker version %v is not installed - use 'dkenv client %v' to install it", version, version)
+		}
+
+		checksum, err := sha256File(d.binaryPath(version))
+		if err != nil {
+			return err
+		}
+
+		entry := &LockEntry{Version: version, OS: runtime.GOOS, Arch: runtime.GOARCH, SHA256: checksum}
+
+		if meta, err := d.loadMeta(version); err == nil {
+			entry.URL = meta.SourceURL
+			entry.Channel = meta.Channel
+		}
+
+		lockfile.Docker = append(lockfile.Docker, entry)
+
+		for _, e := range previous.Docker {
+			if e.Version == version && (e.OS != runtime.GOOS || e.Arch != runtime.GOARCH) {
+				lockfile.Docker = append(lockfile.Docker, e)
+			}
+		}
+	}
+
+	if err := lockfile.write(filename); err != nil {
+		return err
+	}
+
+	log.Infof("Locked %v docker version(s) in %v", len(versions), filename)
+
+	return nil
+}
+
+// Nearest dkenv.lock walking up from dir
+func findLockfile(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for current := dir; ; current = filepath.Dir(current) {
+		filename := filepath.Join(current, LOCK_FILE)
+
+		if _, err := os.Stat(filename); err == nil {
+			return filename, nil
+		}
+
+		if filepath.Dir(current) == current {
+			break
+		}
+	}
+
+	return "", fmt.Errorf("No %v found in %v or its parents - use 'dkenv lock' to create one", LOCK_FILE, dir)
+}
+
+func readLockfile(filename string) (*Lockfile, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open lockfile: %v", err)
+	}
+	defer f.Close()
+
+	lockfile := &Lockfile{}
+
+	if err := json.NewDecoder(f).Decode(lockfile); err != nil {
+		return nil, fmt.Errorf("Unable to parse lockfile '%v': %v", filename, err)
+	}
+
+	if lockfile.LockfileVersion > LOCKFILE_VERSION {
+		return nil, fmt.Errorf("Lockfile '%v' was written by a newer dkenv (version %v, supported %v)",
+			filename, lockfile.LockfileVersion, LOCKFILE_VERSION)
+	}
+
+	// Versions end up in store paths and download URLs
+	for _, entry := range lockfile.Docker {
+		if !versionRegex.MatchString(entry.Version) {
+			return nil, fmt.Errorf("Invalid docker version '%v' in lockfile '%v'", entry.Version, filename)
+		}
+	}
+
+	return lockfile, nil
+}
+
+// Write the lockfile sorted by version and platform, so regenerating it only
+// shows real changes in diffs
+func (l *Lockfile) write(filename string) error {
+	sort.SliceStable(l.Docker, func(i, j int) bool {
+		a, b := l.Docker[i], l.Docker[j]
+
+		if cmp := compareVersions(a.Version, b.Version); cmp != 0 {
+			return cmp < 0
+		}
+
+		return a.OS+"-"+a.Arch < b.OS+"-"+b.Arch
+	})
+
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(filename, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("Unable to write lockfile: %v", err)
+	}
+
+	return nil
+}
+
+// Distinct locked versions, in lockfile order
+func (l *Lockfile) versions() []string {
+	seen := make(map[string]bool)
+	versions := make([]string, 0)
+
+	for _, e := range l.Docker {
+		if !seen[e.Version] {
+			seen[e.Version] = true
+			versions = append(versions, e.Version)
+		}
+	}
+
+	return versions
+}
+
+func (l *Lockfile) find(version, goos, goarch string) *LockEntry {
+	for _, e := range l.Docker {
+		if e.Version == version && e.OS == goos && e.Arch == goarch {
+			return e
+		}
+	}
+
+	return nil
+}
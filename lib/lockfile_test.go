@@ -0,0 +1,170 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockAndSync(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	project, err := ioutil.TempDir("", "tmp_dkenv_project")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir for testing: %v", err)
+	}
+	defer cleanUp(project)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+
+	installFake(t, tmpDkenvDir, "1.9.1")
+	installFake(t, tmpDkenvDir, "1.8.3")
+
+	filename := filepath.Join(project, LOCK_FILE)
+
+	// Nothing to sync or lock yet
+	assert.Error(t, d.SyncAction(project))
+	assert.Error(t, d.LockAction(nil, project))
+	assert.Error(t, d.LockAction([]string{"1.0.0"}, project))
+
+	// Defaults to the version resolved for the directory
+	assert.NoError(t, d.LocalAction("1.9.1", "", project))
+	assert.NoError(t, d.LockAction(nil, project))
+
+	lockfile, err := readLockfile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1.9.1"}, lockfile.versions())
+
+	checksum, _ := sha256File(d.binaryPath("1.9.1"))
+	assert.Equal(t, checksum, lockfile.find("1.9.1", runtime.GOOS, runtime.GOARCH).SHA256)
+
+	// Entries for other platforms survive relocking
+	lockfile.Docker = append(lockfile.Docker, &LockEntry{Version: "1.9.1", OS: "plan9", Arch: "386", SHA256: "abc"})
+	assert.NoError(t, lockfile.write(filename))
+
+	assert.NoError(t, d.LockAction([]string{"1.9.1", "1.8.3"}, project))
+
+	lockfile, err = readLockfile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1.8.3", "1.9.1"}, lockfile.versions())
+	assert.NotNil(t, lockfile.find("1.9.1", "plan9", "386"))
+
+	// Found from subdirectories
+	sub := filepath.Join(project, "sub")
+	os.Mkdir(sub, 0755)
+
+	assert.NoError(t, d.SyncAction(sub))
+
+	// Drift
+	if err := ioutil.WriteFile(d.binaryPath("1.8.3"), []byte("tampered"), 0755); err != nil {
+		t.Fatalf("Unable to modify fake docker binary: %v", err)
+	}
+
+	assert.Error(t, d.SyncAction(project))
+
+	// No digest for this platform
+	assert.NoError(t, (&Lockfile{Docker: []*LockEntry{{Version: "1.9.1", OS: "plan9", Arch: "386"}}}).write(filename))
+	assert.Error(t, d.SyncAction(project))
+
+	// Versions which would escape the store
+	assert.Error(t, d.LockAction([]string{"../../.."}, project))
+
+	assert.NoError(t, (&Lockfile{Docker: []*LockEntry{{Version: "../../..", OS: runtime.GOOS, Arch: runtime.GOARCH, SHA256: "abc"}}}).write(filename))
+	assert.Error(t, d.SyncAction(project))
+	assert.Error(t, d.LockAction(nil, project))
+
+	// Newer lockfile format
+	assert.NoError(t, (&Lockfile{LockfileVersion: LOCKFILE_VERSION + 1}).write(filename))
+	assert.Error(t, d.SyncAction(project))
+}
+
+func TestSyncFromLockedSource(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+
+	if _, err := d.channelDir(CHANNEL_TEST); err != nil {
+		t.Skipf("Channel downloads not supported here: %v", err)
+	}
+
+	// Archives only exist under /locked/ and in the test channel
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/locked/") && !strings.Contains(r.URL.Path, "/static/"+CHANNEL_TEST+"/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write(releaseArchive("client " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	d.ChannelURL = srv.URL
+
+	checksum := func(path string) string {
+		return fmt.Sprintf("%x", sha256.Sum256([]byte("client "+path)))
+	}
+
+	testDir, _ := d.channelDir(CHANNEL_TEST)
+	testPath := strings.TrimPrefix(testDir, srv.URL) + "/docker-17.06.0-ce.tgz"
+
+	project, err := ioutil.TempDir("", "tmp_dkenv_project")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir for testing: %v", err)
+	}
+	defer cleanUp(project)
+
+	filename := filepath.Join(project, LOCK_FILE)
+
+	// From the locked URL
+	lockfile := &Lockfile{Docker: []*LockEntry{{
+		Version: "17.07.0-ce-rc1", OS: runtime.GOOS, Arch: runtime.GOARCH, Channel: CHANNEL_TEST,
+		URL: srv.URL + "/locked/docker-17.07.0-ce-rc1.tgz", SHA256: checksum("/locked/docker-17.07.0-ce-rc1.tgz"),
+	}}}
+
+	// The locked URL is gone, but the version is still in its channel, which
+	// isn't the configured one
+	lockfile.Docker = append(lockfile.Docker, &LockEntry{
+		Version: "17.06.0-ce", OS: runtime.GOOS, Arch: runtime.GOARCH, Channel: CHANNEL_TEST,
+		URL: srv.URL + "/gone/docker-17.06.0-ce.tgz", SHA256: checksum(testPath),
+	})
+
+	assert.NoError(t, lockfile.write(filename))
+	assert.NoError(t, d.SyncAction(project))
+
+	data, _ := ioutil.ReadFile(d.binaryPath("17.07.0-ce-rc1"))
+	assert.Equal(t, "client /locked/docker-17.07.0-ce-rc1.tgz", string(data))
+
+	meta, err := d.loadMeta("17.06.0-ce")
+	assert.NoError(t, err)
+	assert.Equal(t, CHANNEL_TEST, meta.Channel)
+
+	// Relocking keeps the source
+	assert.NoError(t, d.LockAction([]string{"17.06.0-ce"}, project))
+
+	lockfile, err = readLockfile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, CHANNEL_TEST, lockfile.find("17.06.0-ce", runtime.GOOS, runtime.GOARCH).Channel)
+	assert.Equal(t, srv.URL+testPath, lockfile.find("17.06.0-ce", runtime.GOOS, runtime.GOARCH).URL)
+
+	// Not what was locked: nothing gets installed
+	lockfile = &Lockfile{Docker: []*LockEntry{{
+		Version: "17.09.0-ce", OS: runtime.GOOS, Arch: runtime.GOARCH, Channel: CHANNEL_TEST, SHA256: "abc",
+	}}}
+
+	assert.NoError(t, lockfile.write(filename))
+	assert.Error(t, d.SyncAction(project))
+	assert.False(t, d.isInstalled("17.09.0-ce"))
+}
@@ -0,0 +1,151 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	META_DIR = "meta"
+
+	// Bump when InstallMeta changes incompatibly
+	META_SCHEMA_VERSION = 1
+)
+
+// What dkenv knows about an installed version; stored as JSON in
+// DkenvDir/meta/<version>.json
+type InstallMeta struct {
+	SchemaVersion int       `json:"schema_version"`
+	Version       string    `json:"version"`
+	SourceURL     string    `json:"source_url,omitempty"`
+	Mirror        string    `json:"mirror,omitempty"`
+	Channel       string    `json:"channel,omitempty"`
+	SHA256        string    `json:"sha256"`
+	Size          int64     `json:"size"`
+	OS            string    `json:"os"`
+	Arch          string    `json:"arch"`
+	APIVersion    string    `json:"api_version,omitempty"`
+	InstalledAt   time.Time `json:"installed_at"`
+	LastUsed      time.Time `json:"last_used,omitempty"`
+}
+
+func (d *Dkenv) metaPath(version string) string {
+	return d.DkenvDir + "/" + META_DIR + "/" + version + ".json"
+}
+
+// Read the metadata for a version, as written by writeMeta
+func (d *Dkenv) readMeta(version string) (*InstallMeta, error) {
+	data, err := ioutil.ReadFile(d.metaPath(version))
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &InstallMeta{}
+
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, fmt.Errorf("Unable to parse metadata for docker %v: %v", version, err)
+	}
+
+	if meta.SchemaVersion > META_SCHEMA_VERSION {
+		return nil, fmt.Errorf("Metadata for docker %v was written by a newer dkenv (schema %v, supported %v)",
+			version, meta.SchemaVersion, META_SCHEMA_VERSION)
+	}
+
+	return meta, nil
+}
+
+func (d *Dkenv) writeMeta(meta *InstallMeta) error {
+	if err := os.MkdirAll(d.DkenvDir+"/"+META_DIR, 0700); err != nil {
+		return fmt.Errorf("Unable to create metadata dir: %v", err)
+	}
+
+	meta.SchemaVersion = META_SCHEMA_VERSION
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(d.metaPath(meta.Version), data, 0644); err != nil {
+		return fmt.Errorf("Unable to write metadata for docker %v: %v", meta.Version, err)
+	}
+
+	return nil
+}
+
+// Return the metadata for an installed version, backfilling (and saving) it
+// from the binary itself for versions installed before dkenv kept metadata
+func (d *Dkenv) loadMeta(version string) (*InstallMeta, error) {
+	meta, err := d.readMeta(version)
+	if err == nil || !os.IsNotExist(err) {
+		return meta, err
+	}
+
+	path := d.binaryPath(version)
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("Docker version %v is not installed", version)
+	}
+
+	checksum, err := sha256File(path)
+	if err != nil {
+		return nil, err
+	}
+
+	meta = &InstallMeta{
+		Version:     version,
+		SHA256:      checksum,
+		Size:        fi.Size(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		InstalledAt: fi.ModTime(),
+	}
+
+	meta.APIVersion, _ = VersionToApi(version)
+
+	// Last use used to be tracked in the state file
+	if state, err := d.loadState(); err == nil {
+		meta.LastUsed = state.LastUsed[version]
+	}
+
+	if err := d.writeMeta(meta); err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+// Record that a version was just used; failures only affect pruning, so they
+// are not fatal
+func (d *Dkenv) touchVersion(version string) {
+	meta, err := d.loadMeta(version)
+	if err == nil {
+		meta.LastUsed = time.Now()
+		err = d.writeMeta(meta)
+	}
+
+	if err != nil {
+		log.Debugf("Unable to record last use of docker %v: %v", version, err)
+	}
+}
+
+// When a version was last used; falls back to when it was installed
+func (d *Dkenv) lastUsed(version string) time.Time {
+	meta, err := d.loadMeta(version)
+	if err != nil {
+		return time.Time{}
+	}
+
+	if meta.LastUsed.IsZero() {
+		return meta.InstalledAt
+	}
+
+	return meta.LastUsed
+}
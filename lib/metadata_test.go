@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadMeta(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+
+	// Not installed
+	_, err1 := d.loadMeta("1.9.1")
+	assert.Error(t, err1)
+
+	installFake(t, tmpDkenvDir, "1.9.1")
+
+	// Last use recorded by an older dkenv is carried over
+	used := time.Now().Add(-time.Hour).Round(time.Second)
+
+	assert.NoError(t, d.saveState(&State{LastUsed: map[string]time.Time{"1.9.1": used}}))
+
+	// Backfilled from the binary and saved
+	meta, err2 := d.loadMeta("1.9.1")
+	assert.NoError(t, err2)
+	assert.Equal(t, META_SCHEMA_VERSION, meta.SchemaVersion)
+	assert.Equal(t, "1.9.1", meta.Version)
+	assert.Equal(t, "1.21", meta.APIVersion)
+	assert.Equal(t, int64(6), meta.Size)
+	assert.Equal(t, "9a3a45d01531a20e89ac6ae10b0b0beb0492acd7216a368aa062d1a5fecaf9cd", meta.SHA256)
+	assert.True(t, used.Equal(meta.LastUsed))
+
+	_, err3 := os.Stat(d.metaPath("1.9.1"))
+	assert.NoError(t, err3)
+
+	d.touchVersion("1.9.1")
+	assert.True(t, d.lastUsed("1.9.1").After(used))
+
+	// Written by a newer dkenv
+	assert.NoError(t, ioutil.WriteFile(d.metaPath("1.9.1"), []byte(`{"schema_version": 99, "version": "1.9.1"}`), 0644))
+
+	_, err4 := d.readMeta("1.9.1")
+	assert.Error(t, err4)
+}
@@ -0,0 +1,322 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// Partial downloads younger than this may still be in progress
+	PARTIAL_MIN_AGE = time.Hour
+)
+
+// Remove installed versions. Versions which are in use (see inUseVersions)
+// are only removed if force is set.
+func (d *Dkenv) UninstallAction(names []string, force bool) error {
+	inUse := d.inUseVersions()
+
+	versions := make([]string, len(names))
+
+	for i, name := range names {
+		version, err := d.resolveAlias(name)
+		if err != nil {
+			return err
+		}
+
+		versions[i] = version
+
+		if !d.isInstalled(version) {
+			return fmt.Errorf("Docker version %v is not installed", version)
+		}
+
+		if reason, ok := inUse[version]; ok && !force {
+			return fmt.Errorf("Refusing to remove docker %v - %v (use --force to remove anyway)", version, reason)
+		}
+	}
+
+	var reclaimed int64
+
+	for _, version := range versions {
+		size, err := d.removeVersion(version)
+		if err != nil {
+			return err
+		}
+
+		reclaimed += size
+	}
+
+	reclaimed += d.removeStrays()
+
+	log.Infof("Reclaimed %v", humanBytes(reclaimed))
+
+	return nil
+}
+
+// Remove installed versions which are neither among the 'keep' most recently
+// used nor in use, and (if unusedFor is non-zero) haven't been used for at
+// least unusedFor
+func (d *Dkenv) PruneAction(keep int, unusedFor time.Duration) error {
+	installed, err := d.listInstalled()
+	if err != nil {
+		return err
+	}
+
+	versions := make([]string, len(installed))
+	lastUsed := make(map[string]time.Time)
+
+	for i, version := range installed {
+		versions[i] = version
+		lastUsed[version] = d.lastUsed(version)
+	}
+
+	// Most recently used first
+	sort.Slice(versions, func(i, j int) bool {
+		return lastUsed[versions[i]].After(lastUsed[versions[j]])
+	})
+
+	inUse := d.inUseVersions()
+	now := time.Now()
+
+	var reclaimed int64
+
+	for i, version := range versions {
+		switch {
+		case i < keep:
+			log.Debugf("Keeping docker %v - among the %v most recently used", version, keep)
+		case inUse[version] != "":
+			log.Infof("Keeping docker %v - %v", version, inUse[version])
+		case unusedFor > 0 && now.Sub(lastUsed[version]) < unusedFor:
+			log.Debugf("Keeping docker %v - last used %v", version, lastUsed[version].Format(time.RFC3339))
+		default:
+			size, err := d.removeVersion(version)
+			if err != nil {
+				return err
+			}
+
+			reclaimed += size
+		}
+	}
+
+	reclaimed += d.removeStrays()
+
+	log.Infof("Reclaimed %v", humanBytes(reclaimed))
+
+	return nil
+}
+
+// Return installed versions which are currently in use, along with why
+func (d *Dkenv) inUseVersions() map[string]string {
+	inUse := make(map[string]string)
+
+	if info, err := d.inspectLink(d.dockerPath()); err == nil && info.Version != "" {
+		inUse[info.Version] = fmt.Sprintf("%v points at it (%v)", d.dockerPath(), info.Mode)
+	}
+
+	if version, err := readVersionFile(d.globalVersionPath()); err == nil {
+		inUse[version] = "it is the global version"
+	}
+
+	sessions, _ := filepath.Glob(d.sessionsDir() + "/*/docker")
+
+	for _, link := range sessions {
+		// Left behind by a shell which has exited
+		if !sessionAlive(filepath.Dir(link)) {
+			continue
+		}
+
+		if target, err := os.Readlink(link); err == nil {
+			if version, ok := d.versionFromPath(target); ok {
+				inUse[version] = fmt.Sprintf("shell session %v uses it", filepath.Dir(link))
+			}
+		}
+	}
+
+	return inUse
+}
+
+// Remove an installed version (and a BinDir symlink pointing at it); returns
+// the number of bytes freed
+func (d *Dkenv) removeVersion(version string) (int64, error) {
+	path := d.binaryPath(version)
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to stat docker %v: %v", version, err)
+	}
+
+	log.Infof("Removing docker %v (%v)", version, humanBytes(fi.Size()))
+
+	// Copies and hardlinks can't be recognised once the binary is gone
+	linked := false
+
+	if info, err := d.inspectLink(d.dockerPath()); err == nil && info.Version == version {
+		linked = true
+	}
+
+	if err := os.Remove(path); err != nil {
+		return 0, fmt.Errorf("Unable to remove docker %v: %v", version, err)
+	}
+
+	// Builds for other platforms may still live alongside this one
+	versionDir := d.DkenvDir + "/" + VERSIONS_DIR + "/" + version
+
+	if err := os.RemoveAll(filepath.Join(versionDir, platform())); err != nil {
+		log.Warningf("Unable to remove %v: %v", filepath.Join(versionDir, platform()), err)
+	}
+
+	os.Remove(versionDir)
+
+	_, err = os.Stat(versionDir)
+	gone := os.IsNotExist(err)
+
+	if gone {
+		if err := os.Remove(d.metaPath(version)); err != nil && !os.IsNotExist(err) {
+			log.Warningf("Unable to remove metadata for docker %v: %v", version, err)
+		}
+	}
+
+	err = d.updateState(func(state *State) error {
+		if gone {
+			delete(state.LastUsed, version)
+		}
+
+		// Don't leave a dangling link (or stray copy) behind
+		if linked {
+			log.Warningf("Removing %v - it pointed at docker %v", d.dockerPath(), version)
+
+			if err := os.Remove(d.dockerPath()); err != nil {
+				return fmt.Errorf("Unable to remove docker link: %v", err)
+			}
+
+			state.ActiveLink = ""
+		}
+
+		return nil
+	})
+
+	return fi.Size(), err
+}
+
+// Remove untracked docker.*.dkenv backups, temporary symlinks of dkenv
+// processes which are gone, session dirs of exited shells and stale partial
+// downloads; returns the number of bytes freed
+func (d *Dkenv) removeStrays() int64 {
+	// Temporary symlinks are renamed into place while holding the lock
+	l, err := d.lock("link")
+	if err != nil {
+		log.Warningf("Not removing stray files: %v", err)
+		return 0
+	}
+	defer l.unlock()
+
+	state, err := d.loadState()
+	if err != nil {
+		log.Warningf("Not removing stray files: %v", err)
+		return 0
+	}
+
+	tracked := make(map[string]bool)
+
+	for _, b := range state.Backups {
+		tracked[b.Path] = true
+	}
+
+	backups, _ := filepath.Glob(d.dockerPath() + ".*.dkenv")
+	tmpLinks, _ := filepath.Glob(d.BinDir + "/.docker.dkenv-*.tmp")
+	partials, _ := filepath.Glob(filepath.Dir(d.binaryPath("*")) + "/" + PARTIAL_PREFIX + "*")
+
+	// Left behind by dkenv versions predating the versions/ layout
+	legacyPartials, _ := filepath.Glob(d.DkenvDir + "/" + PARTIAL_PREFIX + "*")
+	partials = append(partials, legacyPartials...)
+
+	var strays []string
+
+	for _, path := range backups {
+		if !tracked[path] {
+			strays = append(strays, path)
+		}
+	}
+
+	for _, path := range tmpLinks {
+		if pid, ok := tempLinkPid(path); ok && processRunning(pid) {
+			log.Debugf("Keeping %v - PID %v is still running", path, pid)
+			continue
+		}
+
+		strays = append(strays, path)
+	}
+
+	for _, path := range partials {
+		if fi, err := os.Stat(path); err == nil && time.Since(fi.ModTime()) > PARTIAL_MIN_AGE {
+			strays = append(strays, path)
+		}
+	}
+
+	if sl, err := d.lock("session"); err == nil {
+		d.removeDeadSessions()
+		sl.unlock()
+	} else {
+		log.Warningf("Not removing stale shell sessions: %v", err)
+	}
+
+	var freed int64
+
+	for _, path := range strays {
+		fi, err := os.Lstat(path)
+		if err != nil {
+			continue
+		}
+
+		log.Infof("Removing stray file %v (%v)", path, humanBytes(fi.Size()))
+
+		if err := os.Remove(path); err != nil {
+			log.Warningf("Unable to remove stray file '%v': %v", path, err)
+			continue
+		}
+
+		freed += fi.Size()
+	}
+
+	return freed
+}
+
+// Parse an age such as "90d", "2w" or anything time.ParseDuration accepts
+func ParseAge(age string) (time.Duration, error) {
+	units := map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+
+	for suffix, unit := range units {
+		if strings.HasSuffix(age, suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(age, suffix))
+			if err != nil {
+				return 0, fmt.Errorf("Invalid age '%v'", age)
+			}
+
+			if n < 0 {
+				return 0, fmt.Errorf("Invalid age '%v' - ages can't be negative", age)
+			}
+
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	duration, err := time.ParseDuration(age)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid age '%v'", age)
+	}
+
+	if duration < 0 {
+		return 0, fmt.Errorf("Invalid age '%v' - ages can't be negative", age)
+	}
+
+	return duration, nil
+}
@@ -0,0 +1,210 @@
+package lib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUninstallAction(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+
+	for _, version := range []string{"1.8.3", "1.9.1"} {
+		installFake(t, tmpDkenvDir, version)
+	}
+
+	assert.NoError(t, d.UpdateSymlink("1.9.1"))
+
+	// Not installed
+	assert.Error(t, d.UninstallAction([]string{"1.0.0"}, false))
+
+	// Active version needs --force
+	assert.Error(t, d.UninstallAction([]string{"1.9.1"}, false))
+	assert.True(t, d.isInstalled("1.9.1"))
+
+	assert.NoError(t, d.UninstallAction([]string{"1.8.3"}, false))
+	assert.False(t, d.isInstalled("1.8.3"))
+
+	// Forced removal also removes the (otherwise dangling) symlink
+	assert.NoError(t, d.UninstallAction([]string{"1.9.1"}, true))
+	assert.False(t, d.isInstalled("1.9.1"))
+
+	_, err := os.Lstat(d.dockerPath())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPruneAction(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+
+	versions := []string{"1.6.2", "1.7.1", "1.8.3", "1.9.1"}
+
+	for _, version := range versions {
+		installFake(t, tmpDkenvDir, version)
+	}
+
+	// 1.6.2 is active but least recently used; 1.9.1 most recently used
+	assert.NoError(t, d.UpdateSymlink("1.6.2"))
+
+	old := time.Now().Add(-100 * 24 * time.Hour)
+
+	lastUsed := map[string]time.Time{
+		"1.6.2": old.Add(-time.Hour),
+		"1.7.1": old,
+		"1.8.3": time.Now().Add(-time.Hour),
+		"1.9.1": time.Now(),
+	}
+
+	for version, when := range lastUsed {
+		meta, err := d.loadMeta(version)
+		assert.NoError(t, err)
+
+		meta.LastUsed = when
+		assert.NoError(t, d.writeMeta(meta))
+	}
+
+	// Stray backup & stale partial download
+	assert.NoError(t, ioutil.WriteFile(d.dockerPath()+".1234.dkenv", []byte("backup"), 0755))
+	assert.NoError(t, ioutil.WriteFile(tmpDkenvDir+"/"+PARTIAL_PREFIX+"1.10.3-123", []byte("partial"), 0644))
+	assert.NoError(t, os.Chtimes(tmpDkenvDir+"/"+PARTIAL_PREFIX+"1.10.3-123", old, old))
+
+	// Keep 1, but only remove versions unused for 90 days
+	assert.NoError(t, d.PruneAction(1, 90*24*time.Hour))
+
+	assert.True(t, d.isInstalled("1.6.2"))  // in use
+	assert.False(t, d.isInstalled("1.7.1")) // unused for 100 days
+	assert.True(t, d.isInstalled("1.8.3"))  // recently used
+	assert.True(t, d.isInstalled("1.9.1"))  // most recently used
+
+	_, err := os.Stat(d.dockerPath() + ".1234.dkenv")
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(tmpDkenvDir + "/" + PARTIAL_PREFIX + "1.10.3-123")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestUninstallIgnoresExitedSessions(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+
+	sessions := map[string]int{"1.8.3": os.Getpid(), "1.9.1": deadPid(t)}
+
+	for version, pid := range sessions {
+		installFake(t, tmpDkenvDir, version)
+
+		dir := d.sessionsDir() + "/" + version
+		assert.NoError(t, os.MkdirAll(dir, 0700))
+		assert.NoError(t, os.Symlink(d.binaryPath(version), dir+"/docker"))
+		assert.NoError(t, ioutil.WriteFile(dir+"/"+SESSION_OWNER_FILE, []byte(fmt.Sprintf("%v\n", pid)), 0600))
+	}
+
+	// The shell of this session is still running
+	assert.Error(t, d.UninstallAction([]string{"1.8.3"}, false))
+	assert.True(t, d.isInstalled("1.8.3"))
+
+	// This one's shell has exited, so its session doesn't count and is
+	// cleaned up
+	assert.NoError(t, d.UninstallAction([]string{"1.9.1"}, false))
+	assert.False(t, d.isInstalled("1.9.1"))
+
+	_, err := os.Stat(d.sessionsDir() + "/1.9.1")
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(d.sessionsDir() + "/1.8.3")
+	assert.NoError(t, err)
+}
+
+func TestRemoveStrayTempLinks(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+
+	// One of a running process (us), one of a process which is long gone
+	running := tempLinkPath(d.dockerPath())
+	gone := tmpBinDir + "/.docker.dkenv-99999999.tmp"
+
+	for _, path := range []string{running, gone} {
+		assert.NoError(t, os.Symlink("/nonexistent", path))
+	}
+
+	d.removeStrays()
+
+	_, err := os.Lstat(running)
+	assert.NoError(t, err)
+
+	_, err = os.Lstat(gone)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestUninstallKeepsOtherPlatforms(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+
+	installFake(t, tmpDkenvDir, "1.9.1")
+	assert.NoError(t, d.writeMeta(&InstallMeta{Version: "1.9.1"}))
+
+	other := filepath.Join(tmpDkenvDir, VERSIONS_DIR, "1.9.1", "plan9-mips", "bin")
+	assert.NoError(t, os.MkdirAll(other, 0755))
+	assert.NoError(t, ioutil.WriteFile(other+"/docker", []byte("binary"), 0755))
+
+	assert.NoError(t, d.UninstallAction([]string{"1.9.1"}, false))
+	assert.False(t, d.isInstalled("1.9.1"))
+
+	// The other build still needs its metadata
+	_, err := os.Stat(d.metaPath("1.9.1"))
+	assert.NoError(t, err)
+
+	// Gone once the last build is
+	assert.NoError(t, os.RemoveAll(filepath.Dir(other)))
+	installFake(t, tmpDkenvDir, "1.9.1")
+	assert.NoError(t, d.UninstallAction([]string{"1.9.1"}, false))
+
+	_, err = os.Stat(d.metaPath("1.9.1"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestParseAge(t *testing.T) {
+	age1, err1 := ParseAge("90d")
+	assert.NoError(t, err1)
+	assert.Equal(t, 90*24*time.Hour, age1)
+
+	age2, err2 := ParseAge("2w")
+	assert.NoError(t, err2)
+	assert.Equal(t, 14*24*time.Hour, age2)
+
+	age3, err3 := ParseAge("36h")
+	assert.NoError(t, err3)
+	assert.Equal(t, 36*time.Hour, age3)
+
+	_, err4 := ParseAge("xd")
+	assert.Error(t, err4)
+
+	_, err5 := ParseAge("soon")
+	assert.Error(t, err5)
+
+	_, err6 := ParseAge("-5d")
+	assert.Error(t, err6)
+
+	_, err7 := ParseAge("-1h")
+	assert.Error(t, err7)
+}
@@ -0,0 +1,82 @@
+package lib
+
+import (
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	// Docker releases published as plain binaries under DEFAULT_MIRROR, oldest
+	// first; later releases only ship as archives
+	legacyReleases = []string{
+		"1.0.0", "1.0.1",
+		"1.1.0", "1.1.1", "1.1.2",
+		"1.2.0",
+		"1.3.0", "1.3.1", "1.3.2", "1.3.3",
+		"1.4.0", "1.4.1",
+		"1.5.0",
+		"1.6.0", "1.6.1", "1.6.2",
+		"1.7.0", "1.7.1",
+		"1.8.0", "1.8.1", "1.8.2", "1.8.3",
+		"1.9.0", "1.9.1",
+		"1.10.0", "1.10.1", "1.10.2", "1.10.3",
+	}
+)
+
+// Versions dkenv knows how to install: the releases in the configured channel
+// (see remoteVersions) plus anything already installed (eg. from a custom
+// mirror), oldest first
+func (d *Dkenv) knownVersions() ([]string, error) {
+	var releases []string
+
+	if _, _, err := channelPlatform(); err != nil {
+		// Only the plain binaries on the mirrors exist for this system
+		log.Warningf("%v - only listing docker releases before 17.03", err)
+		releases = legacyReleases
+	} else if releases, err = d.remoteVersions(d.channel()); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	versions := make([]string, 0, len(releases))
+
+	installed, _ := d.listInstalled()
+
+	for _, version := range append(releases, installed...) {
+		if !seen[version] {
+			seen[version] = true
+			versions = append(versions, version)
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return compareVersions(versions[i], versions[j]) < 0
+	})
+
+	return versions, nil
+}
+
+// Newest release among versions starting with prefix ("1.9" matches "1.9.1"
+// but not "1.90.0"); an empty prefix matches everything. Pre-releases are
+// skipped unless prefix is one.
+func latestMatching(versions []string, prefix string) (string, bool) {
+	latest := ""
+
+	for _, version := range versions {
+		if prefix != "" && version != prefix && !strings.HasPrefix(version, prefix+".") && !strings.HasPrefix(version, prefix+"-") {
+			continue
+		}
+
+		if isPrerelease(version) && !isPrerelease(prefix) {
+			continue
+		}
+
+		if latest == "" || compareVersions(version, latest) > 0 {
+			latest = version
+		}
+	}
+
+	return latest, latest != ""
+}
@@ -0,0 +1,228 @@
+package lib
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	LOCAL_VERSION_FILE  = ".docker-version"
+	GLOBAL_VERSION_FILE = "version"
+	VERSION_ENV         = "DKENV_DOCKER_VERSION"
+)
+
+var (
+	errNoVersion = errors.New("No docker version configured")
+
+	// Pre-release and build suffixes start with - or +, so leftovers such as
+	// 1.9.1.partial or 1.9.1.bak are not versions
+	versionRegex = regexp.MustCompile(`^[0-9]+\.[0-9]+(\.[0-9]+)?([-+][0-9A-Za-z.-]+)?$`)
+)
+
+// Where a resolved docker version came from
+type Resolution struct {
+	Version string
+	Source  string // "env", "local", "global", "config", "session" or "symlink"
+	Origin  string // Env var, file or link the version was read from
+	Reason  string // Human readable explanation of why this source won
+}
+
+// Figure out which docker version applies to a directory: DKENV_DOCKER_VERSION
+// wins if set, then the nearest .docker-version or .tool-versions docker entry
+// walking up from dir (.docker-version first within a directory), falling
+// back to the global default in DkenvDir. The result is checked to be a valid
+// version, as it ends up in store paths and download URLs.
+func (d *Dkenv) ResolveVersion(dir string) (*Resolution, error) {
+	res, err := d.findVersion(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if !versionRegex.MatchString(res.Version) {
+		return nil, fmt.Errorf("Invalid docker version '%v' in %v", res.Version, res.Origin)
+	}
+
+	return res, nil
+}
+
+// Find the version ResolveVersion reports, without checking it
+func (d *Dkenv) findVersion(dir string) (*Resolution, error) {
+	if version := os.Getenv(VERSION_ENV); version != "" {
+		reason := fmt.Sprintf("%v is set in the environment", VERSION_ENV)
+
+		return &Resolution{Version: version, Source: "env", Origin: VERSION_ENV, Reason: reason}, nil
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for current := dir; ; current = filepath.Dir(current) {
+		filename := filepath.Join(current, LOCAL_VERSION_FILE)
+
+		version, err := readVersionFile(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		if err == nil {
+			reason := fmt.Sprintf("nearest %v walking up from %v", LOCAL_VERSION_FILE, dir)
+
+			return &Resolution{Version: version, Source: "local", Origin: filename, Reason: reason}, nil
+		}
+
+		filename = filepath.Join(current, TOOL_VERSIONS_FILE)
+
+		version, err = readToolVersion(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		if err == nil && version != "" {
+			reason := fmt.Sprintf("nearest %v with a %v entry walking up from %v", TOOL_VERSIONS_FILE, TOOL_VERSIONS_NAME, dir)
+
+			return &Resolution{Version: version, Source: "local", Origin: filename, Reason: reason}, nil
+		}
+
+		if filepath.Dir(current) == current {
+			break
+		}
+	}
+
+	filename := d.globalVersionPath()
+
+	version, err := readVersionFile(filename)
+	if err != nil && os.IsNotExist(err) && d.DefaultVersion != "" {
+		reason := fmt.Sprintf("no %v or %v found in %v or its parents and no global version set; using default_version from the config",
+			LOCAL_VERSION_FILE, TOOL_VERSIONS_FILE, dir)
+
+		return &Resolution{Version: d.DefaultVersion, Source: "config", Origin: "default_version", Reason: reason}, nil
+	}
+
+	if err != nil && os.IsNotExist(err) {
+		return nil, errNoVersion
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	reason := fmt.Sprintf("no %v or %v found in %v or its parents; using global default", LOCAL_VERSION_FILE, TOOL_VERSIONS_FILE, dir)
+
+	return &Resolution{Version: version, Source: "global", Origin: filename, Reason: reason}, nil
+}
+
+// Pin a docker version for a directory (and its children), in a
+// .docker-version file or, with the LOCAL_FORMAT_TOOL_VERSIONS format, as the
+// docker entry of .tool-versions. Aliases are pinned as the version they
+// resolve to.
+func (d *Dkenv) LocalAction(version, format, dir string) error {
+	version, err := d.resolveAlias(version)
+	if err != nil {
+		return err
+	}
+
+	var filename string
+
+	switch format {
+	case "", LOCAL_FORMAT_DOCKER_VERSION:
+		filename = filepath.Join(dir, LOCAL_VERSION_FILE)
+
+		if err := d.writeVersionFile(filename, version); err != nil {
+			return err
+		}
+	case LOCAL_FORMAT_TOOL_VERSIONS:
+		filename = filepath.Join(dir, TOOL_VERSIONS_FILE)
+
+		if err := d.writeToolVersion(filename, version); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Unknown version file format '%v'", format)
+	}
+
+	log.Infof("Wrote docker version %v to %v", version, filename)
+
+	return nil
+}
+
+// Set the docker version (or alias, pinned as the version it resolves to)
+// used when no .docker-version file applies
+func (d *Dkenv) GlobalAction(version string) error {
+	version, err := d.resolveAlias(version)
+	if err != nil {
+		return err
+	}
+
+	if err := d.writeVersionFile(d.globalVersionPath(), version); err != nil {
+		return err
+	}
+
+	log.Infof("Set global docker version to %v", version)
+
+	return nil
+}
+
+func (d *Dkenv) globalVersionPath() string {
+	return d.DkenvDir + "/" + GLOBAL_VERSION_FILE
+}
+
+func (d *Dkenv) writeVersionFile(filename, version string) error {
+	if err := d.checkPinnedVersion(version); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(filename, []byte(version+"\n"), 0644); err != nil {
+		return fmt.Errorf("Unable to write version file '%v': %v", filename, err)
+	}
+
+	return nil
+}
+
+// Validate a version about to be pinned, warning if it isn't installed
+func (d *Dkenv) checkPinnedVersion(version string) error {
+	if !versionRegex.MatchString(version) {
+		return fmt.Errorf("Invalid docker version '%v'", version)
+	}
+
+	if !d.isInstalled(version) {
+		log.Warningf("Docker version %v is not installed yet - use 'dkenv client %v' to install it", version, version)
+	}
+
+	return nil
+}
+
+// Return the first non-blank, non-comment line of a version file
+func readVersionFile(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		return line, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("Unable to read version file '%v': %v", filename, err)
+	}
+
+	return "", fmt.Errorf("Version file '%v' is empty", filename)
+}
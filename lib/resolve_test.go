@@ -0,0 +1,115 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveVersion(t *testing.T) {
+	tmpDkenvDir, tmpProjectDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpProjectDir)
+
+	d := New(tmpDkenvDir, tmpProjectDir)
+
+	nested := filepath.Join(tmpProjectDir, "a", "b")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("Unable to create nested dir: %v", err)
+	}
+
+	// Nothing configured
+	_, err := d.ResolveVersion(nested)
+	assert.Equal(t, errNoVersion, err)
+
+	// Configured default_version
+	d.DefaultVersion = "1.7.1"
+
+	res, err := d.ResolveVersion(nested)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.7.1", res.Version)
+	assert.Equal(t, "config", res.Source)
+
+	// Global default
+	assert.NoError(t, d.GlobalAction("1.8.3"))
+
+	res, err = d.ResolveVersion(nested)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.8.3", res.Version)
+	assert.Equal(t, "global", res.Source)
+	assert.Equal(t, d.globalVersionPath(), res.Origin)
+
+	// Nearest local file wins
+	assert.NoError(t, d.LocalAction("1.9.1", "", tmpProjectDir))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(nested, LOCAL_VERSION_FILE), []byte("# comment\n\n 1.10.3 \n"), 0644))
+
+	res, err = d.ResolveVersion(nested)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.10.3", res.Version)
+	assert.Equal(t, "local", res.Source)
+	assert.Equal(t, filepath.Join(nested, LOCAL_VERSION_FILE), res.Origin)
+
+	res, err = d.ResolveVersion(filepath.Join(tmpProjectDir, "a"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1.9.1", res.Version)
+
+	// Environment beats everything
+	os.Setenv(VERSION_ENV, "1.6.2")
+	defer os.Unsetenv(VERSION_ENV)
+
+	res, err = d.ResolveVersion(nested)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.6.2", res.Version)
+	assert.Equal(t, "env", res.Source)
+
+	// Garbage versions are rejected
+	assert.Error(t, d.LocalAction("not a version", "", tmpProjectDir))
+}
+
+func TestResolveInvalidVersion(t *testing.T) {
+	tmpDkenvDir, tmpProjectDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpProjectDir)
+
+	d := New(tmpDkenvDir, tmpProjectDir)
+
+	// Values which would escape the versions dir
+	d.DefaultVersion = "../../.."
+
+	_, err := d.ResolveVersion(tmpProjectDir)
+	assert.Error(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(d.globalVersionPath(), []byte("../../bin\n"), 0644))
+
+	_, err = d.ResolveVersion(tmpProjectDir)
+	assert.Error(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(tmpProjectDir, TOOL_VERSIONS_FILE), []byte("docker ../../..\n"), 0644))
+
+	_, err = d.ResolveVersion(tmpProjectDir)
+	assert.Error(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(tmpProjectDir, LOCAL_VERSION_FILE), []byte("../../..\n"), 0644))
+
+	_, err = d.ResolveVersion(tmpProjectDir)
+	assert.Error(t, err)
+
+	os.Setenv(VERSION_ENV, "1.9.1/../../..")
+	defer os.Unsetenv(VERSION_ENV)
+
+	_, err = d.ResolveVersion(tmpProjectDir)
+	assert.Error(t, err)
+}
+
+func TestVersionRegex(t *testing.T) {
+	for _, version := range []string{"1.9", "1.9.1", "17.06.0-ce", "17.07.0-ce-rc1", "18.09.1-beta2"} {
+		assert.True(t, versionRegex.MatchString(version), version)
+	}
+
+	for _, version := range []string{"1", "1.9.1.partial", "1.9.1.bak", ".1.9.1", "1.9.1/..", "latest"} {
+		assert.False(t, versionRegex.MatchString(version), version)
+	}
+}
@@ -0,0 +1,86 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	// Startup file to add PATH changes to, per shell
+	shellRcFiles = map[string]string{
+		"bash":       "~/.bashrc",
+		"zsh":        "~/.zshrc",
+		"fish":       "~/.config/fish/config.fish",
+		"powershell": "$PROFILE",
+	}
+)
+
+// Report whether dir is on PATH
+func onPath(dir string) bool {
+	for _, entry := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.Clean(entry) == filepath.Clean(dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Return the line which puts dir in front of PATH in the given shell's syntax
+func pathHint(shell, dir string) string {
+	switch shell {
+	case "fish":
+		return fmt.Sprintf("fish_add_path %v", quoteFish(dir))
+	case "powershell":
+		return fmt.Sprintf("$Env:PATH = %v + [IO.Path]::PathSeparator + $Env:PATH", quotePowershell(dir))
+	}
+
+	return fmt.Sprintf("export PATH=\"%v:$PATH\"", strings.Replace(dir, `"`, `\"`, -1))
+}
+
+// Tell the user how to put BinDir on PATH if it isn't
+func (d *Dkenv) warnIfNotOnPath() {
+	if onPath(d.BinDir) {
+		return
+	}
+
+	shell := DetectShell()
+
+	log.Warningf("%v is not on PATH - add this line to %v:", d.BinDir, shellRcFiles[shell])
+
+	// Not logged, so it can be copied as is
+	fmt.Fprintf(os.Stderr, "\n    %v\n\n", pathHint(shell, d.BinDir))
+}
+
+// Same as replaceSymlink, but through sudo; used for bin dirs the user can't
+// write to, so that only this step runs as root
+func sudoReplaceSymlink(src, dst string) error {
+	tmp := tempLinkPath(dst)
+
+	log.Infof("Using sudo to update '%v'", dst)
+
+	if err := sudo("ln", "-sf", src, tmp); err != nil {
+		return err
+	}
+
+	return sudo("mv", "-f", tmp, dst)
+}
+
+// Run a command through sudo, letting it prompt for a password
+func sudo(args ...string) error {
+	cmd := exec.Command("sudo", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("'sudo %v' failed: %v", strings.Join(args, " "), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package lib
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathHint(t *testing.T) {
+	assert.Equal(t, `export PATH="/home/u/.local/bin:$PATH"`, pathHint("bash", "/home/u/.local/bin"))
+	assert.Equal(t, `fish_add_path '/home/u/.local/bin'`, pathHint("fish", "/home/u/.local/bin"))
+	assert.Equal(t, `$Env:PATH = 'C:\bin' + [IO.Path]::PathSeparator + $Env:PATH`, pathHint("powershell", `C:\bin`))
+}
+
+func TestOnPath(t *testing.T) {
+	oldPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", oldPath)
+
+	os.Setenv("PATH", "/usr/bin:/home/u/.local/bin/:/bin")
+
+	assert.True(t, onPath("/home/u/.local/bin"))
+	assert.False(t, onPath("/usr/local/bin"))
+}
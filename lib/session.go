@@ -0,0 +1,247 @@
+package lib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	SESSION_ENV = "DKENV_SESSION_DIR"
+	SESSION_DIR = "sessions"
+
+	// PID of the shell owning a session dir
+	SESSION_OWNER_FILE = "owner"
+)
+
+// Print shell code which puts a per-session bin dir containing the requested
+// docker version at the front of PATH; or, if unset is true, code which
+// undoes it
+func (d *Dkenv) ShellAction(version, shell string, unset bool) error {
+	if shell == "" {
+		shell = DetectShell()
+	}
+
+	if shell == "powershell" {
+		return fmt.Errorf("Session switching is not supported for %v", shell)
+	}
+
+	if unset {
+		if os.Getenv(SESSION_ENV) == "" {
+			return fmt.Errorf("No dkenv session active in this shell")
+		}
+
+		lines, err := d.unsetSession(shell)
+		if err != nil {
+			return err
+		}
+
+		log.Info("Removed dkenv session from this shell")
+		d.printLines(lines...)
+
+		return nil
+	}
+
+	version, err := d.resolveAlias(version)
+	if err != nil {
+		return err
+	}
+
+	if !d.isInstalled(version) {
+		log.Infof("Docker version %v not found - attempting to download...", version)
+
+		if err := d.DownloadDocker(version); err != nil {
+			return err
+		}
+	}
+
+	lines, err := d.switchSession(version, shell)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Using docker %v for this shell session", version)
+	d.printLines(lines...)
+
+	return nil
+}
+
+// Point the session's docker at an (installed) version; returns the shell
+// code activating the session
+func (d *Dkenv) switchSession(version, shell string) ([]string, error) {
+	sessionDir, err := d.sessionDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := replaceSymlink(d.binaryPath(version), sessionDir+"/docker"); err != nil {
+		return nil, fmt.Errorf("Unable to create session docker symlink: %v", err)
+	}
+
+	d.touchVersion(version)
+
+	path := append([]string{sessionDir}, withoutPath(os.Getenv("PATH"), sessionDir)...)
+
+	return []string{
+		exportLine(shell, SESSION_ENV, sessionDir),
+		pathLine(shell, path),
+		rehashLine(shell),
+	}, nil
+}
+
+// Remove the session dir (if any); returns the shell code deactivating the
+// session
+func (d *Dkenv) unsetSession(shell string) ([]string, error) {
+	sessionDir := os.Getenv(SESSION_ENV)
+
+	if sessionDir != "" && d.isSessionDir(sessionDir) {
+		if err := os.RemoveAll(sessionDir); err != nil {
+			return nil, fmt.Errorf("Unable to remove session dir '%v': %v", sessionDir, err)
+		}
+	}
+
+	return []string{
+		unsetLine(shell, SESSION_ENV),
+		pathLine(shell, withoutPath(os.Getenv("PATH"), sessionDir)),
+		rehashLine(shell),
+	}, nil
+}
+
+// Return the current shell's session dir, creating a new one if there is
+// none. Session dirs of shells which have exited are removed on the way.
+func (d *Dkenv) sessionDir() (string, error) {
+	l, err := d.lock("session")
+	if err != nil {
+		return "", err
+	}
+	defer l.unlock()
+
+	if dir := os.Getenv(SESSION_ENV); dir != "" && d.isSessionDir(dir) {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", fmt.Errorf("Unable to create session dir '%v': %v", dir, err)
+		}
+
+		if !sessionAlive(dir) {
+			if err := writeSessionOwner(dir); err != nil {
+				return "", err
+			}
+		}
+
+		d.removeDeadSessions()
+
+		return dir, nil
+	}
+
+	d.removeDeadSessions()
+
+	if err := os.MkdirAll(d.sessionsDir(), 0700); err != nil {
+		return "", fmt.Errorf("Unable to create sessions dir: %v", err)
+	}
+
+	dir, err := ioutil.TempDir(d.sessionsDir(), "")
+	if err != nil {
+		return "", fmt.Errorf("Unable to create session dir: %v", err)
+	}
+
+	if err := writeSessionOwner(dir); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// Record the shell owning a session dir; dkenv runs from the shell's
+// eval "$(dkenv shell ...)", so that is our parent process
+func writeSessionOwner(dir string) error {
+	owner := fmt.Sprintf("%v\n", os.Getppid())
+
+	if err := ioutil.WriteFile(dir+"/"+SESSION_OWNER_FILE, []byte(owner), 0600); err != nil {
+		return fmt.Errorf("Unable to record session owner: %v", err)
+	}
+
+	return nil
+}
+
+// Report whether the shell owning a session dir is still running; dirs
+// without an owner were left behind by older dkenv versions
+func sessionAlive(dir string) bool {
+	data, err := ioutil.ReadFile(dir + "/" + SESSION_OWNER_FILE)
+	if err != nil {
+		return false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+
+	return err == nil && processRunning(pid)
+}
+
+// Remove session dirs whose shell has exited (closed terminal, 'exit', kill)
+// without running 'dkenv shell --unset'; the caller holds the session lock
+func (d *Dkenv) removeDeadSessions() {
+	dirs, _ := filepath.Glob(d.sessionsDir() + "/*")
+
+	for _, dir := range dirs {
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() || sessionAlive(dir) {
+			continue
+		}
+
+		log.Debugf("Removing session dir %v - its shell has exited", dir)
+
+		if err := os.RemoveAll(dir); err != nil {
+			log.Warningf("Unable to remove session dir '%v': %v", dir, err)
+		}
+	}
+}
+
+func (d *Dkenv) sessionsDir() string {
+	return d.CacheDir + "/" + SESSION_DIR
+}
+
+// Only ever touch directories that dkenv created
+func (d *Dkenv) isSessionDir(dir string) bool {
+	return filepath.Dir(filepath.Clean(dir)) == d.sessionsDir()
+}
+
+// Split a PATH value, dropping any occurrence of dir
+func withoutPath(path, dir string) []string {
+	result := make([]string, 0)
+
+	for _, entry := range filepath.SplitList(path) {
+		if entry != "" && filepath.Clean(entry) != filepath.Clean(dir) {
+			result = append(result, entry)
+		}
+	}
+
+	return result
+}
+
+// Return a line which sets PATH to entries in the given shell's syntax
+func pathLine(shell string, entries []string) string {
+	if shell == "fish" {
+		quoted := make([]string, len(entries))
+
+		for i, entry := range entries {
+			quoted[i] = quoteFish(entry)
+		}
+
+		return fmt.Sprintf("set -gx PATH %v;", strings.Join(quoted, " "))
+	}
+
+	return exportLine(shell, "PATH", strings.Join(entries, string(os.PathListSeparator)))
+}
+
+// Return a line which makes the shell forget cached command locations (fish
+// doesn't cache them)
+func rehashLine(shell string) string {
+	if shell == "fish" {
+		return ""
+	}
+
+	return "hash -r 2>/dev/null || true"
+}
@@ -0,0 +1,85 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShellAction(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	installFake(t, tmpDkenvDir, "1.9.1")
+
+	oldPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", oldPath)
+	defer os.Unsetenv(SESSION_ENV)
+
+	os.Setenv("PATH", "/usr/bin:/bin")
+	os.Unsetenv(SESSION_ENV)
+
+	out := &bytes.Buffer{}
+
+	d := New(tmpDkenvDir, tmpBinDir)
+	d.Out = out
+
+	// Left behind by a shell which exited without --unset
+	stale := d.sessionsDir() + "/stale"
+	assert.NoError(t, os.MkdirAll(stale, 0700))
+	assert.NoError(t, ioutil.WriteFile(stale+"/"+SESSION_OWNER_FILE, []byte(fmt.Sprintf("%v\n", deadPid(t))), 0600))
+
+	assert.NoError(t, d.ShellAction("1.9.1", "bash", false))
+
+	_, err := os.Stat(stale)
+	assert.True(t, os.IsNotExist(err))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], "export "+SESSION_ENV+"='"+d.sessionsDir()+"/")
+
+	sessionDir := strings.Trim(strings.SplitN(lines[0], "=", 2)[1], "'")
+	assert.True(t, d.isSessionDir(sessionDir))
+	assert.Equal(t, "export PATH='"+sessionDir+":/usr/bin:/bin'", lines[1])
+	assertLink(t, sessionDir+"/docker", d.binaryPath("1.9.1"))
+	assert.True(t, sessionAlive(sessionDir))
+
+	// Switching again reuses the session dir and doesn't duplicate PATH entries
+	os.Setenv(SESSION_ENV, sessionDir)
+	os.Setenv("PATH", sessionDir+":/usr/bin:/bin")
+	out.Reset()
+
+	assert.NoError(t, d.ShellAction("1.9.1", "fish", false))
+	assert.Contains(t, out.String(), "set -gx PATH '"+sessionDir+"' '/usr/bin' '/bin';")
+
+	// Unset removes the dir from PATH and from disk
+	out.Reset()
+
+	assert.NoError(t, d.ShellAction("", "zsh", true))
+	assert.Contains(t, out.String(), "unset "+SESSION_ENV)
+	assert.Contains(t, out.String(), "export PATH='/usr/bin:/bin'")
+
+	_, err = os.Stat(sessionDir)
+	assert.True(t, os.IsNotExist(err))
+
+	// Nothing to unset
+	os.Unsetenv(SESSION_ENV)
+	assert.Error(t, d.ShellAction("", "bash", true))
+}
+
+// PID of a process which has exited
+func deadPid(t *testing.T) int {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Unable to run test process: %v", err)
+	}
+
+	return cmd.Process.Pid
+}
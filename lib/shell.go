@@ -0,0 +1,78 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+var (
+	supportedShells = []string{"bash", "zsh", "fish", "powershell"}
+)
+
+// Figure out which shell syntax to emit when none was explicitly requested
+func DetectShell() string {
+	if runtime.GOOS == "windows" {
+		return "powershell"
+	}
+
+	shell := filepath.Base(os.Getenv("SHELL"))
+
+	for _, s := range supportedShells {
+		if s == shell {
+			return s
+		}
+	}
+
+	return "bash"
+}
+
+// Return a line which sets (and exports) an environment variable in the given
+// shell's syntax
+func exportLine(shell, name, value string) string {
+	switch shell {
+	case "fish":
+		return fmt.Sprintf("set -gx %v %v;", name, quoteFish(value))
+	case "powershell":
+		return fmt.Sprintf("$Env:%v = %v", name, quotePowershell(value))
+	}
+
+	return fmt.Sprintf("export %v=%v", name, quotePosix(value))
+}
+
+// Return a line which removes an environment variable in the given shell's
+// syntax
+func unsetLine(shell, name string) string {
+	switch shell {
+	case "fish":
+		return fmt.Sprintf("set -e %v;", name)
+	case "powershell":
+		return fmt.Sprintf("Remove-Item Env:%v -ErrorAction SilentlyContinue", name)
+	}
+
+	return fmt.Sprintf("unset %v", name)
+}
+
+func quotePosix(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+func quoteFish(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	return "'" + strings.Replace(value, "'", `\'`, -1) + "'"
+}
+
+func quotePowershell(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+// Print shell code to Out, one statement per line, skipping empty ones
+func (d *Dkenv) printLines(lines ...string) {
+	for _, line := range lines {
+		if line != "" {
+			fmt.Fprintln(d.Out, line)
+		}
+	}
+}
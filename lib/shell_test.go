@@ -0,0 +1,25 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportLine(t *testing.T) {
+	assert.Equal(t, "export DOCKER_API_VERSION='1.18'", exportLine("bash", "DOCKER_API_VERSION", "1.18"))
+	assert.Equal(t, "export DOCKER_API_VERSION='1.18'", exportLine("zsh", "DOCKER_API_VERSION", "1.18"))
+	assert.Equal(t, "set -gx DOCKER_API_VERSION '1.18';", exportLine("fish", "DOCKER_API_VERSION", "1.18"))
+	assert.Equal(t, "$Env:DOCKER_API_VERSION = '1.18'", exportLine("powershell", "DOCKER_API_VERSION", "1.18"))
+
+	// Quoting
+	assert.Equal(t, `export FOO='it'\''s'`, exportLine("bash", "FOO", "it's"))
+	assert.Equal(t, `set -gx FOO 'it\'s';`, exportLine("fish", "FOO", "it's"))
+	assert.Equal(t, `$Env:FOO = 'it''s'`, exportLine("powershell", "FOO", "it's"))
+}
+
+func TestUnsetLine(t *testing.T) {
+	assert.Equal(t, "unset DOCKER_API_VERSION", unsetLine("bash", "DOCKER_API_VERSION"))
+	assert.Equal(t, "set -e DOCKER_API_VERSION;", unsetLine("fish", "DOCKER_API_VERSION"))
+	assert.Equal(t, "Remove-Item Env:DOCKER_API_VERSION -ErrorAction SilentlyContinue", unsetLine("powershell", "DOCKER_API_VERSION"))
+}
@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// The shim runs for every docker command, so it only records the last
+	// use of a version (see touchVersion) once per interval
+	SHIM_TOUCH_INTERVAL = time.Hour
+)
+
+// Whether dkenv was invoked through the docker shim (ie. as 'docker')
+func IsShimInvocation(arg0 string) bool {
+	name := strings.TrimSuffix(filepath.Base(arg0), ".exe")
+
+	return name == "docker"
+}
+
+// Run the docker version which applies to the current directory, forwarding
+// args, stdio, exit status and signals. Only returns on error.
+func (d *Dkenv) ShimExec(args []string) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("Unable to determine current directory: %v", err)
+	}
+
+	path, err := d.shimBinary(dir)
+	if err != nil {
+		return err
+	}
+
+	return execBinary(path, args, os.Environ())
+}
+
+// Return the installed docker binary which applies to dir, recording that it
+// is being used
+func (d *Dkenv) shimBinary(dir string) (string, error) {
+	res, err := d.ResolveVersion(dir)
+	if err == errNoVersion {
+		return "", fmt.Errorf("%v - use 'dkenv local' or 'dkenv global' to set one", err)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if !d.isInstalled(res.Version) {
+		return "", fmt.Errorf("Docker version %v (set by %v) is not installed - use 'dkenv client %v' to install it",
+			res.Version, res.Origin, res.Version)
+	}
+
+	if time.Since(d.lastUsed(res.Version)) > SHIM_TOUCH_INTERVAL {
+		d.touchVersion(res.Version)
+	}
+
+	return d.binaryPath(res.Version), nil
+}
+
+// Replace BinDir/docker with the version resolving shim (a symlink to the
+// dkenv executable itself)
+func (d *Dkenv) InstallShimAction() error {
+	exe, err := shimTarget()
+	if err != nil {
+		return err
+	}
+
+	if err := d.linkDocker(exe, LINK_SHIM); err != nil {
+		return err
+	}
+
+	log.Infof("Docker shim installed - use 'dkenv local' or 'dkenv global' to pick versions")
+
+	return nil
+}
+
+// Go back to a plain symlink, pointing at the version which applies to dir
+func (d *Dkenv) RemoveShimAction(dir string) error {
+	if !d.shimInstalled() {
+		return fmt.Errorf("Docker shim is not installed in %v", d.BinDir)
+	}
+
+	res, err := d.ResolveVersion(dir)
+	if err != nil {
+		return fmt.Errorf("Unable to determine docker version to switch to: %v", err)
+	}
+
+	if !d.isInstalled(res.Version) {
+		log.Infof("Docker version %v not found - attempting to download...", res.Version)
+
+		if err := d.DownloadDocker(res.Version); err != nil {
+			return err
+		}
+	}
+
+	return d.UpdateSymlink(res.Version)
+}
+
+// Whether BinDir/docker is currently the dkenv shim
+func (d *Dkenv) shimInstalled() bool {
+	info, err := d.inspectLink(d.dockerPath())
+
+	return err == nil && info.Mode == LINK_SHIM
+}
+
+// Absolute, symlink free path to the running dkenv executable
+func shimTarget() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("Unable to determine dkenv executable: %v", err)
+	}
+
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return "", fmt.Errorf("Unable to determine dkenv executable: %v", err)
+	}
+
+	return exe, nil
+}
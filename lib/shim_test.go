@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsShimInvocation(t *testing.T) {
+	assert.True(t, IsShimInvocation("docker"))
+	assert.True(t, IsShimInvocation("/usr/local/bin/docker"))
+	assert.True(t, IsShimInvocation("docker.exe"))
+	assert.False(t, IsShimInvocation("/usr/local/bin/dkenv"))
+	assert.False(t, IsShimInvocation("docker-1.9.1"))
+}
+
+func TestShimInstalled(t *testing.T) {
+	tmpDkenvDir, tmpBinDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpBinDir)
+
+	d := New(tmpDkenvDir, tmpBinDir)
+	assert.False(t, d.shimInstalled())
+
+	assert.NoError(t, d.InstallShimAction())
+	assert.True(t, d.shimInstalled())
+
+	exe, err := shimTarget()
+	assert.NoError(t, err)
+	assertLink(t, d.dockerPath(), exe)
+
+	// Still the shim after dkenv was moved or upgraded
+	for _, target := range []string{"/opt/old/dkenv", "/opt/dkenv/dkenv-1.2.0-linux-amd64"} {
+		assert.NoError(t, replaceSymlink(target, d.dockerPath()))
+		assert.True(t, d.shimInstalled(), target)
+	}
+
+	assert.NoError(t, replaceSymlink("/usr/bin/docker", d.dockerPath()))
+	assert.False(t, d.shimInstalled())
+}
+
+func TestShimBinary(t *testing.T) {
+	tmpDkenvDir, tmpProjectDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpProjectDir)
+
+	d := New(tmpDkenvDir, "")
+
+	_, err := d.shimBinary(tmpProjectDir)
+	assert.Error(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(tmpProjectDir, LOCAL_VERSION_FILE), []byte("1.9.1\n"), 0644))
+
+	// Pinned, but not installed
+	_, err = d.shimBinary(tmpProjectDir)
+	assert.Error(t, err)
+
+	installFake(t, tmpDkenvDir, "1.9.1")
+
+	// Last used long ago
+	meta, err := d.loadMeta("1.9.1")
+	assert.NoError(t, err)
+
+	meta.InstalledAt = time.Now().Add(-365 * 24 * time.Hour)
+	assert.NoError(t, d.writeMeta(meta))
+
+	path, err := d.shimBinary(tmpProjectDir)
+	assert.NoError(t, err)
+	assert.Equal(t, d.binaryPath("1.9.1"), path)
+
+	// Using a version through the shim keeps prune from removing it
+	assert.WithinDuration(t, time.Now(), d.lastUsed("1.9.1"), time.Minute)
+}
@@ -0,0 +1,100 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const (
+	STATE_FILE = "state.json"
+
+	// User config, which lives in DkenvDir when DKENV_DIR is set (see
+	// cli.UserConfigPath)
+	CONFIG_FILE = "config.yaml"
+)
+
+// Persistent dkenv bookkeeping, stored as JSON in DkenvDir
+type State struct {
+	Backups []*Backup `json:"backups,omitempty"`
+
+	// Target of the last docker symlink created in BinDir; used to detect
+	// changes made outside of dkenv
+	ActiveLink string `json:"active_link,omitempty"`
+
+	// User defined version aliases (see AliasAction)
+	Aliases map[string]string `json:"aliases,omitempty"`
+
+	// Deprecated: last use is tracked in the per-version metadata now; only
+	// read to backfill it
+	LastUsed map[string]time.Time `json:"last_used,omitempty"`
+}
+
+// A non-dkenv docker binary which was moved aside by UpdateSymlink
+type Backup struct {
+	ID           string    `json:"id"`
+	Path         string    `json:"path"`
+	OriginalPath string    `json:"original_path"`
+	SHA256       string    `json:"sha256"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (d *Dkenv) statePath() string {
+	return d.DkenvDir + "/" + STATE_FILE
+}
+
+// Load state from disk; a missing state file results in an empty state
+func (d *Dkenv) loadState() (*State, error) {
+	state := &State{}
+
+	data, err := ioutil.ReadFile(d.statePath())
+	if err != nil && os.IsNotExist(err) {
+		return state, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read dkenv state: %v", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("Unable to parse dkenv state '%v': %v", d.statePath(), err)
+	}
+
+	return state, nil
+}
+
+func (d *Dkenv) saveState(state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(d.statePath(), data, 0600); err != nil {
+		return fmt.Errorf("Unable to write dkenv state: %v", err)
+	}
+
+	return nil
+}
+
+// Load state, hand it to fn and save it if fn succeeds. Holds the state lock
+// throughout, so fn must not call updateState itself.
+func (d *Dkenv) updateState(fn func(*State) error) error {
+	l, err := d.lock("state")
+	if err != nil {
+		return err
+	}
+	defer l.unlock()
+
+	state, err := d.loadState()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(state); err != nil {
+		return err
+	}
+
+	return d.saveState(state)
+}
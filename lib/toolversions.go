@@ -0,0 +1,123 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// asdf/mise version file, shared with other tools
+	TOOL_VERSIONS_FILE = ".tool-versions"
+	TOOL_VERSIONS_NAME = "docker"
+
+	LOCAL_FORMAT_DOCKER_VERSION = "docker-version"
+	LOCAL_FORMAT_TOOL_VERSIONS  = "tool-versions"
+)
+
+// Return the docker version from a .tool-versions file; empty if the file has
+// no (usable) docker entry. Lines look like "<tool> <version> [<fallback>...]", and only
+// the first version is used.
+func readToolVersion(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		fields := toolVersionFields(scanner.Text())
+
+		if len(fields) == 0 || fields[0] != TOOL_VERSIONS_NAME {
+			continue
+		}
+
+		// Like asdf, skip entries meaning nothing to dkenv, eg. "system"
+		if len(fields) < 2 || !versionRegex.MatchString(fields[1]) {
+			log.Warningf("Ignoring '%v' in %v - not a docker version", strings.Join(fields, " "), filename)
+			continue
+		}
+
+		return fields[1], nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("Unable to read '%v': %v", filename, err)
+	}
+
+	return "", nil
+}
+
+// Set the docker entry of a .tool-versions file, leaving every other line
+// untouched; appends an entry if there is none
+func (d *Dkenv) writeToolVersion(filename, version string) error {
+	if err := d.checkPinnedVersion(version); err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Unable to read '%v': %v", filename, err)
+	}
+
+	perm := os.FileMode(0644)
+	if fi, err := os.Stat(filename); err == nil {
+		perm = fi.Mode().Perm()
+	}
+
+	entry := TOOL_VERSIONS_NAME + " " + version
+
+	lines := make([]string, 0)
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+
+	found := false
+
+	for i, line := range lines {
+		fields := toolVersionFields(line)
+
+		if len(fields) > 0 && fields[0] == TOOL_VERSIONS_NAME {
+			// Keep a trailing comment, if any
+			if idx := strings.Index(line, "#"); idx >= 0 {
+				entry += " " + line[idx:]
+			}
+
+			lines[i] = entry
+			found = true
+
+			break
+		}
+	}
+
+	if !found {
+		lines = append(lines, entry)
+	}
+
+	// Keep a symlinked file (eg. managed by a dotfile manager) a symlink
+	if resolved, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = resolved
+	}
+
+	if err := writeFileAtomic(filename, []byte(strings.Join(lines, "\n")+"\n"), perm); err != nil {
+		return fmt.Errorf("Unable to write '%v': %v", filename, err)
+	}
+
+	return nil
+}
+
+// Split a .tool-versions line into fields, dropping comments
+func toolVersionFields(line string) []string {
+	if idx := strings.Index(line, "#"); idx >= 0 {
+		line = line[:idx]
+	}
+
+	return strings.Fields(line)
+}
@@ -0,0 +1,105 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolVersions(t *testing.T) {
+	tmpDkenvDir, tmpProjectDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpProjectDir)
+
+	d := New(tmpDkenvDir, tmpProjectDir)
+
+	nested := filepath.Join(tmpProjectDir, "a")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("Unable to create nested dir: %v", err)
+	}
+
+	filename := filepath.Join(tmpProjectDir, TOOL_VERSIONS_FILE)
+	original := "# tools\nnodejs 18.1.0\ndocker 1.8.3 # pinned for CI\npython 3.11.2 3.10.1\n"
+
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(original), 0644))
+
+	res, err := d.ResolveVersion(nested)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.8.3", res.Version)
+	assert.Equal(t, "local", res.Source)
+	assert.Equal(t, filename, res.Origin)
+
+	// Updates the docker entry in place
+	assert.NoError(t, d.LocalAction("1.9.1", LOCAL_FORMAT_TOOL_VERSIONS, tmpProjectDir))
+
+	data, _ := ioutil.ReadFile(filename)
+	assert.Equal(t, "# tools\nnodejs 18.1.0\ndocker 1.9.1 # pinned for CI\npython 3.11.2 3.10.1\n", string(data))
+
+	// .docker-version beats .tool-versions in the same directory...
+	assert.NoError(t, d.LocalAction("1.10.3", LOCAL_FORMAT_DOCKER_VERSION, tmpProjectDir))
+
+	res, err = d.ResolveVersion(nested)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.10.3", res.Version)
+
+	// ...but not a nearer .tool-versions
+	nestedFile := filepath.Join(nested, TOOL_VERSIONS_FILE)
+	assert.NoError(t, ioutil.WriteFile(nestedFile, []byte("ruby 3.2.0"), 0644))
+
+	res, err = d.ResolveVersion(nested)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.10.3", res.Version)
+
+	assert.NoError(t, d.LocalAction("1.7.1", LOCAL_FORMAT_TOOL_VERSIONS, nested))
+
+	data, _ = ioutil.ReadFile(nestedFile)
+	assert.Equal(t, "ruby 3.2.0\ndocker 1.7.1\n", string(data))
+
+	res, err = d.ResolveVersion(nested)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.7.1", res.Version)
+	assert.Equal(t, nestedFile, res.Origin)
+
+	assert.Error(t, d.LocalAction("1.7.1", "bogus", nested))
+}
+
+func TestToolVersionsSymlink(t *testing.T) {
+	tmpDkenvDir, tmpProjectDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpProjectDir)
+
+	d := New(tmpDkenvDir, tmpProjectDir)
+
+	// Managed elsewhere, eg. by a dotfile manager
+	dotfile := filepath.Join(tmpDkenvDir, "dotfiles-tool-versions")
+	filename := filepath.Join(tmpProjectDir, TOOL_VERSIONS_FILE)
+
+	assert.NoError(t, ioutil.WriteFile(dotfile, []byte("nodejs 18.1.0\n"), 0644))
+	assert.NoError(t, os.Symlink(dotfile, filename))
+
+	assert.NoError(t, d.LocalAction("1.9.1", LOCAL_FORMAT_TOOL_VERSIONS, tmpProjectDir))
+	assertLink(t, filename, dotfile)
+
+	data, _ := ioutil.ReadFile(dotfile)
+	assert.Equal(t, "nodejs 18.1.0\ndocker 1.9.1\n", string(data))
+}
+
+func TestToolVersionsNotAVersion(t *testing.T) {
+	tmpDkenvDir, tmpProjectDir := makeTestDirs(t)
+	defer cleanUp(tmpDkenvDir)
+	defer cleanUp(tmpProjectDir)
+
+	d := New(tmpDkenvDir, tmpProjectDir)
+	d.DefaultVersion = "1.8.3"
+
+	// asdf's "use the system docker" entry is skipped
+	filename := filepath.Join(tmpProjectDir, TOOL_VERSIONS_FILE)
+	assert.NoError(t, ioutil.WriteFile(filename, []byte("docker system\n"), 0644))
+
+	res, err := d.ResolveVersion(tmpProjectDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.8.3", res.Version)
+}
@@ -0,0 +1,245 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Letters followed by a number, eg. "rc10"
+	identRegex = regexp.MustCompile(`^([A-Za-z]+)([0-9]+)$`)
+
+	// Bare major version, allowed in ranges ("<18")
+	majorRegex = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// Compare two dotted version strings (eg. "1.9.1" vs "1.10.0", or API
+// versions such as "1.18" vs "1.22").
+//
+// Returns -1 if a < b, 0 if they are equal and 1 if a > b. Numeric parts are
+// compared numerically, anything else is compared as a plain string.
+// Pre-releases sort before the release ("17.06.0-ce-rc1" < "17.06.0-ce"); the
+// -ce/-ee edition suffix is ignored.
+func compareVersions(a, b string) int {
+	aMain, aPre := splitPrerelease(a)
+	bMain, bPre := splitPrerelease(b)
+
+	if cmp := compareDotted(aMain, bMain); cmp != 0 {
+		return cmp
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+
+	return compareDotted(aPre, bPre)
+}
+
+// Split a version into its release part and pre-release identifier (empty
+// for releases), dropping build metadata and the docker edition
+func splitPrerelease(version string) (string, string) {
+	if idx := strings.Index(version, "+"); idx >= 0 {
+		version = version[:idx]
+	}
+
+	idx := strings.Index(version, "-")
+	if idx < 0 {
+		return version, ""
+	}
+
+	pre := version[idx+1:]
+
+	for _, edition := range []string{"ce", "ee"} {
+		if pre == edition {
+			pre = ""
+		}
+
+		pre = strings.TrimPrefix(pre, edition+"-")
+	}
+
+	return version[:idx], pre
+}
+
+func isPrerelease(version string) bool {
+	_, pre := splitPrerelease(version)
+	return pre != ""
+}
+
+func compareDotted(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart string
+
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+
+		if cmp := comparePart(aPart, bPart); cmp != 0 {
+			return cmp
+		}
+	}
+
+	return 0
+}
+
+func comparePart(a, b string) int {
+	aNum, aErr := strconv.Atoi(a)
+	bNum, bErr := strconv.Atoi(b)
+
+	// Missing parts count as zero ("1.9" == "1.9.0")
+	if a == "" {
+		aNum, aErr = 0, nil
+	}
+
+	if b == "" {
+		bNum, bErr = 0, nil
+	}
+
+	if aErr == nil && bErr == nil {
+		return compareInts(aNum, bNum)
+	}
+
+	// Pre-release identifiers such as "rc2" vs "rc10"
+	aMatch := identRegex.FindStringSubmatch(a)
+	bMatch := identRegex.FindStringSubmatch(b)
+
+	if aMatch != nil && bMatch != nil && aMatch[1] == bMatch[1] {
+		aNum, _ = strconv.Atoi(aMatch[2])
+		bNum, _ = strconv.Atoi(bMatch[2])
+
+		return compareInts(aNum, bNum)
+	}
+
+	return strings.Compare(a, b)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+// A version constraint such as ">=17.03"; a constraint without operator
+// matches versions starting with it (see latestMatching)
+type versionConstraint struct {
+	op      string
+	version string
+}
+
+// Parse a version range: constraints separated by commas or spaces, all of
+// which must match (eg. ">=17.03, <18")
+func parseRange(expr string) ([]*versionConstraint, error) {
+	constraints := make([]*versionConstraint, 0)
+
+	for _, field := range strings.FieldsFunc(expr, func(r rune) bool { return r == ',' || r == ' ' }) {
+		c := &versionConstraint{version: field}
+
+		for _, op := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(field, op) {
+				c.op = op
+				c.version = strings.TrimSpace(strings.TrimPrefix(field, op))
+
+				break
+			}
+		}
+
+		if !versionRegex.MatchString(c.version) && !majorRegex.MatchString(c.version) {
+			return nil, fmt.Errorf("Invalid version constraint '%v'", field)
+		}
+
+		constraints = append(constraints, c)
+	}
+
+	if len(constraints) == 0 {
+		return nil, fmt.Errorf("Empty version range")
+	}
+
+	return constraints, nil
+}
+
+// Report whether a string is meant as a version range rather than a version
+func isRange(expr string) bool {
+	return strings.ContainsAny(expr, "<>=,")
+}
+
+// Check version against all constraints. Pre-releases only match if pre is
+// set or a constraint names a pre-release itself.
+func matchesRange(version string, constraints []*versionConstraint, pre bool) bool {
+	if isPrerelease(version) && !pre {
+		named := false
+
+		for _, c := range constraints {
+			if isPrerelease(c.version) {
+				named = true
+			}
+		}
+
+		if !named {
+			return false
+		}
+	}
+
+	for _, c := range constraints {
+		cmp := compareVersions(version, c.version)
+
+		var ok bool
+
+		switch c.op {
+		case ">=":
+			ok = cmp >= 0
+		case "<=":
+			ok = cmp <= 0
+		case ">":
+			ok = cmp > 0
+		case "<":
+			ok = cmp < 0
+		case "=":
+			ok = cmp == 0
+		default:
+			ok = version == c.version || strings.HasPrefix(version, c.version+".") || strings.HasPrefix(version, c.version+"-")
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Return the API version spoken by a given docker client version
+func VersionToApi(version string) (string, error) {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return "", errors.New("Invalid client version")
+	}
+
+	minor := parts[0] + "." + parts[1]
+
+	for api, clientVersion := range apiVersions {
+		if strings.HasPrefix(clientVersion, minor+".") {
+			return api, nil
+		}
+	}
+
+	return "", errors.New("Unknown API version for client version")
+}
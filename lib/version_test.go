@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 0, compareVersions("1.9.1", "1.9.1"))
+	assert.Equal(t, 0, compareVersions("1.9", "1.9.0"))
+	assert.Equal(t, -1, compareVersions("1.9.1", "1.10.0"))
+	assert.Equal(t, 1, compareVersions("1.22", "1.18"))
+	assert.Equal(t, -1, compareVersions("1.12", "1.12.1"))
+
+	// Pre-releases
+	assert.Equal(t, -1, compareVersions("17.06.0-ce-rc1", "17.06.0-ce"))
+	assert.Equal(t, -1, compareVersions("17.06.0-ce-rc2", "17.06.0-ce-rc10"))
+	assert.Equal(t, -1, compareVersions("18.09.0-beta3", "18.09.0-rc1"))
+	assert.Equal(t, 1, compareVersions("17.06.0-ce-rc1", "17.05.0-ce"))
+	assert.Equal(t, 0, compareVersions("17.03.0-ce", "17.03.0"))
+	assert.Equal(t, 1, compareVersions("17.03.0-ce", "1.13.1"))
+}
+
+func TestMatchesRange(t *testing.T) {
+	constraints, err := parseRange(">=17.03, <17.07")
+	assert.NoError(t, err)
+
+	assert.True(t, matchesRange("17.03.0-ce", constraints, false))
+	assert.True(t, matchesRange("17.06.2-ce", constraints, false))
+	assert.False(t, matchesRange("17.07.0-ce", constraints, false))
+	assert.False(t, matchesRange("1.13.1", constraints, false))
+
+	// Pre-releases only when asked for
+	assert.False(t, matchesRange("17.06.0-ce-rc1", constraints, false))
+	assert.True(t, matchesRange("17.06.0-ce-rc1", constraints, true))
+
+	constraints, _ = parseRange(">=17.06.0-ce-rc1")
+	assert.True(t, matchesRange("17.06.0-ce-rc2", constraints, false))
+
+	// Bare versions match as prefixes
+	constraints, _ = parseRange("17.06")
+	assert.True(t, matchesRange("17.06.1-ce", constraints, false))
+	assert.False(t, matchesRange("17.09.0-ce", constraints, false))
+
+	_, err = parseRange(">=banana")
+	assert.Error(t, err)
+
+	assert.True(t, isRange(">=17.03"))
+	assert.False(t, isRange("17.03.0-ce"))
+}
+
+func TestVersionToApi(t *testing.T) {
+	api1, err1 := VersionToApi("1.9.0")
+	assert.NoError(t, err1)
+	assert.Equal(t, "1.21", api1)
+
+	api2, err2 := VersionToApi("1.10.3")
+	assert.NoError(t, err2)
+	assert.Equal(t, "1.22", api2)
+
+	_, err3 := VersionToApi("0.5.0")
+	assert.Error(t, err3)
+
+	_, err4 := VersionToApi("garbage")
+	assert.Error(t, err4)
+}
@@ -1,46 +1,165 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
 	"github.com/newrelic/dkenv/cli"
 	"github.com/newrelic/dkenv/lib"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/mitchellh/go-homedir"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 const (
-	VERSION           = "1.1.1"
-	DEFAULT_BINDIR    = "/usr/local/bin"
-	DEFAULT_DKENV_DIR = "~/.dkenv"
+	VERSION = "1.1.1"
 )
 
 var (
-	binDir   = kingpin.Flag("bindir", "Directory to create symlinks for Docker binaries").Default(DEFAULT_BINDIR).String()
+	// Defaults for these come from the config (see 'dkenv config list')
+	binDir   = kingpin.Flag("bindir", "Directory to create symlinks for Docker binaries (default /usr/local/bin)").String()
 	homeDir  = kingpin.Flag("homedir", "Override automatically found homedir").String()
-	dkenvDir = kingpin.Flag("dkenvdir", "Directory to store Docker binaries").Default(DEFAULT_DKENV_DIR).String()
+	dkenvDir = kingpin.Flag("dkenvdir", "Directory to store Docker binaries (default $XDG_DATA_HOME/dkenv, or ~/.dkenv if it exists)").String()
 	debug    = kingpin.Flag("debug", "Enable debug output").Short('d').Bool()
+	userMode = kingpin.Flag("user", "Use ~/.local/bin if the bin directory isn't writable").Bool()
+	sudo     = kingpin.Flag("sudo", "Use sudo for the final link step if the bin directory isn't writable").Bool()
+	channel  = kingpin.Flag("channel", "Release channel for Docker 17.03 and later: "+strings.Join(lib.Channels, ", ")+" (default stable)").Enum(lib.Channels...)
+	linkMode = kingpin.Flag("link-mode", "How to put docker in the bin directory: "+strings.Join(lib.LinkModes, ", ")+" (default symlink)").Enum(lib.LinkModes...)
+	lockWait = kingpin.Flag("lock-timeout", "How long to wait for other dkenv processes").Default("1m").Duration()
 
 	// Commands
 	client    = kingpin.Command("client", "Download/switch Docker binary by *client* version")
-	clientArg = client.Arg("version", "Docker client version").Required().String()
+	clientArg = client.Arg("version", "Docker client version, alias (see 'dkenv alias') or range (eg. '>=17.03')").Required().String()
 	api       = kingpin.Command("api", "Download/switch Docker binary by *API* version")
 	apiArg    = api.Arg("version", "Docker API version").Required().String()
+	list      = kingpin.Command("list", "List downloaded/existing Docker binaries")
+	listRem   = kingpin.Command("list-remote", "List Docker versions available for download")
+	listRemRg = listRem.Arg("range", "Only show versions matching a range (eg. '>=17.03, <18')").String()
+	listRemPr = listRem.Flag("pre", "Include pre-releases").Bool()
+	listOut   = list.Flag("output", "Output format: table, json, yaml or template=<template> (eg. template='{{.Version}} {{.API}}')").Short('o').Default("table").String()
+	env       = kingpin.Command("env", "Print shell exports pinning DOCKER_API_VERSION for the current shell")
+	envArg    = env.Arg("auto", "Use 'auto' to pin to the API version reported by the docker daemon").Enum("auto")
+	envApi    = env.Flag("api", "Docker API version to pin").String()
+	envShell  = env.Flag("shell", "Shell syntax to emit (bash, zsh, fish, powershell)").Enum("bash", "zsh", "fish", "powershell")
+	backups   = kingpin.Command("backups", "List backups of docker binaries replaced by dkenv")
+	bkPrune   = backups.Flag("prune", "Remove all but the newest backups").Bool()
+	bkKeep    = backups.Flag("keep", "Number of backups to keep when pruning").Default("1").Int()
+	restore   = kingpin.Command("restore", "Restore a docker binary replaced by dkenv")
+	restoreId = restore.Arg("id", "Backup to restore (defaults to the most recent one)").String()
+	restoreF  = restore.Flag("force", "Restore even if the backup has been modified").Bool()
+	local     = kingpin.Command("local", "Pin a Docker version for the current directory (writes .docker-version)")
+	localArg  = local.Arg("version", "Docker client version or alias").Required().String()
+	localFmt  = local.Flag("format", "Version file to write (docker-version, tool-versions)").Default("docker-version").Enum("docker-version", "tool-versions")
+	global    = kingpin.Command("global", "Set the Docker version used when no .docker-version or .tool-versions applies")
+	globalArg = global.Arg("version", "Docker client version or alias").Required().String()
+	current   = kingpin.Command("current", "Show the active Docker version, its API version and how it was chosen")
+	which     = kingpin.Command("which", "Show the absolute path of the Docker binary which would run")
+	shim      = kingpin.Command("shim", "Replace the docker symlink with a shim which picks the version on every invocation")
+	shimRm    = shim.Flag("remove", "Remove the shim and go back to a plain symlink").Bool()
+	execCmd   = kingpin.Command("exec", "Run a specific Docker version without switching (eg. dkenv exec 1.6.2 -- docker ps)")
+	execArg   = execCmd.Arg("version", "Docker client version or alias").Required().String()
+	execArgs  = execCmd.Arg("args", "Docker command and arguments").Strings()
+	execApi   = execCmd.Flag("api", "Set DOCKER_API_VERSION for the command").String()
+	shell     = kingpin.Command("shell", "Print shell code switching Docker for the current shell only (use with eval)")
+	shellArg  = shell.Arg("version", "Docker client version or alias").String()
+	shellRm   = shell.Flag("unset", "Undo the session switch").Bool()
+	shellType = shell.Flag("shell", "Shell syntax to emit (bash, zsh, fish)").Enum("bash", "zsh", "fish")
+	hook      = kingpin.Command("hook", "Print a shell hook which switches Docker on directory change")
+	hookArg   = hook.Arg("shell", "Shell to print the hook for (bash, zsh, fish)").Required().Enum("bash", "zsh", "fish")
+	hookAuto  = hook.Flag("auto-install", "Download missing versions instead of warning about them").Bool()
+	hookEnv   = kingpin.Command("hook-env", "Used by the shell hook").Hidden()
+	hookShell = hookEnv.Flag("shell", "Shell syntax to emit (bash, zsh, fish)").Required().Enum("bash", "zsh", "fish")
+	hookEnvAI = hookEnv.Flag("auto-install", "Download missing versions instead of warning about them").Bool()
+	uninstall = kingpin.Command("uninstall", "Remove installed Docker versions")
+	uninstArg = uninstall.Arg("version", "Docker client versions or aliases").Required().Strings()
+	uninstF   = uninstall.Flag("force", "Remove versions even if they are in use").Bool()
+	pruneCmd  = kingpin.Command("prune", "Remove Docker versions which haven't been used recently")
+	pruneKeep = pruneCmd.Flag("keep", "Number of most recently used versions to keep").Default("3").Int()
+	pruneAge  = pruneCmd.Flag("unused-for", "Only remove versions unused for this long (eg. 90d, 2w, 36h)").String()
+	use       = kingpin.Command("use", "Switch to an installed Docker version ('-' for the previous one)")
+	useArg    = use.Arg("version", "Docker client version or alias, or '-'").Required().String()
+	alias     = kingpin.Command("alias", "Show, set or remove named Docker version aliases")
+	aliasName = alias.Arg("name", "Alias name (shows all aliases if omitted)").String()
+	aliasArg  = alias.Arg("version", "Docker client version to point the alias at (shows the alias if omitted)").String()
+	aliasRm   = alias.Flag("remove", "Remove the alias").Bool()
+	history   = kingpin.Command("history", "Show Docker switch history")
+	histLimit = history.Flag("limit", "Only show the most recent entries").Short('n').Int()
+	syncCmd   = kingpin.Command("sync", "Install the Docker versions in dkenv.lock and verify their digests")
+	lockCmd   = kingpin.Command("lock", "Write dkenv.lock from the installed Docker versions")
+	lockArg   = lockCmd.Arg("version", "Docker client versions to lock (default: the ones already locked, or the current one)").Strings()
+	asdf      = kingpin.Command("asdf", "asdf/mise plugin backend (reads ASDF_INSTALL_VERSION/ASDF_INSTALL_PATH)")
+	asdfList  = asdf.Command("list-all", "Print all installable Docker versions")
+	asdfLS    = asdf.Command("latest-stable", "Print the newest Docker version")
+	asdfLSArg = asdfLS.Arg("query", "Version prefix to match").String()
+	asdfDL    = asdf.Command("download", "Download ASDF_INSTALL_VERSION (to ASDF_DOWNLOAD_PATH, if set)")
+	asdfInst  = asdf.Command("install", "Install ASDF_INSTALL_VERSION to ASDF_INSTALL_PATH/bin/docker")
+	doctor    = kingpin.Command("doctor", "Diagnose problems with the dkenv setup")
+	docJSON   = doctor.Flag("json", "Output results as JSON").Bool()
+	config    = kingpin.Command("config", "Show or change dkenv settings")
+	cfgGet    = config.Command("get", "Show a setting's value and where it came from")
+	cfgGetKey = cfgGet.Arg("key", "Setting name").Required().String()
+	cfgSet    = config.Command("set", "Store a setting in a config file")
+	cfgSetKey = cfgSet.Arg("key", "Setting name").Required().String()
+	cfgSetVal = cfgSet.Arg("value", "Setting value (comma separated for lists)").Required().String()
+	cfgScope  = cfgSet.Flag("scope", "Config file to write (user, project, system)").Default("user").Enum("user", "project", "system")
+	cfgList   = config.Command("list", "Show all settings and where their values came from")
 
-	// Not sure if there is any other, non-hacky way to set a command with no
-	// args and retain the ability to see if it was called.
-	list = kingpin.Command("list", "List downloaded/existing Docker binaries").Action(func(c *kingpin.ParseContext) error {
-		listAction = true
-		return nil
-	})
-
-	listAction = false
+	command  string
+	cacheDir string
+	settings *cli.Config
 )
 
 func init() {
+	// Invoked as 'docker' through the shim; never returns
+	if lib.IsShimInvocation(os.Args[0]) {
+		runShim()
+	}
+
 	kingpin.Version(VERSION)
-	kingpin.Parse()
+	command = kingpin.MustParse(kingpin.CommandLine.Parse(cli.EscapeDashArg(os.Args[1:], use.FullCommand())))
+
+	var err error
+
+	settings, err = cli.LoadConfig(map[string]string{
+		"bindir":    *binDir,
+		"dkenvdir":  *dkenvDir,
+		"homedir":   *homeDir,
+		"debug":     boolFlag(*debug),
+		"user_mode": boolFlag(*userMode),
+		"sudo":      boolFlag(*sudo),
+		"link_mode": *linkMode,
+		"channel":   *channel,
+	}, workDir())
+	if err != nil {
+		log.Fatalf("Config error: %v", err)
+	}
+
+	*binDir = settings.Get("bindir")
+	*dkenvDir = settings.Get("dkenvdir")
+	*homeDir = settings.Get("homedir")
+	*debug = settings.Bool("debug")
+	*userMode = settings.Bool("user_mode")
+	*sudo = settings.Bool("sudo")
+	*linkMode = settings.Get("link_mode")
+	*channel = settings.Get("channel")
+	cacheDir = settings.Get("cachedir")
+
+	// Don't let a broken setting get in the way of fixing it
+	if strings.HasPrefix(command, config.FullCommand()) {
+		return
+	}
 
 	c := cli.New(binDir, homeDir, dkenvDir)
+	c.CacheDir = &cacheDir
+
+	// Without root, fall back to ~/.local/bin - unless asked for a specific
+	// bin dir, or to use sudo
+	c.Rootless = *userMode || (settings.Source("bindir") == cli.SOURCE_DEFAULT && !*sudo)
+	c.Link = linksDocker(command)
+
 	if err := c.HandleArgs(); err != nil {
 		log.Fatalf("Argument error: %v", err)
 	}
@@ -50,21 +169,231 @@ func init() {
 	}
 }
 
+// Whether a command (possibly) links docker into the bin dir
+func linksDocker(command string) bool {
+	switch command {
+	case client.FullCommand(), api.FullCommand(), env.FullCommand(), use.FullCommand(),
+		shim.FullCommand(), restore.FullCommand():
+		return true
+	}
+
+	return false
+}
+
 func main() {
+	if strings.HasPrefix(command, config.FullCommand()) {
+		runConfig()
+		return
+	}
+
 	d := lib.New(*dkenvDir, *binDir)
+	d.Trigger = "dkenv " + strings.Join(os.Args[1:], " ")
+	d.LockTimeout = *lockWait
+	d.CacheDir = cacheDir
+	d.Sudo = *sudo
+	d.LinkMode = *linkMode
+	d.Channel = *channel
+	d.ChannelURL = settings.Get("channel_url")
+	d.Mirrors = settings.List("mirrors")
+	d.Proxy = settings.Get("proxy")
+	d.DefaultVersion = settings.Get("default_version")
+
+	// Logs always go to stderr; stdout is for output meant for scripts and
+	// the shell (eg. 'eval $(dkenv env)', '$(dkenv which)')
+	log.SetOutput(os.Stderr)
+
+	if err := d.MigrateStore(); err != nil {
+		log.Fatal(err.Error())
+	}
 
 	var err error
 
-	switch {
-	case listAction:
-		err = d.ListAction()
-	case *apiArg != "":
+	switch command {
+	case list.FullCommand():
+		err = d.ListAction(*listOut, workDir())
+	case listRem.FullCommand():
+		err = d.ListRemoteAction(*channel, *listRemRg, *listRemPr)
+	case api.FullCommand():
 		err = d.FetchVersionAction(*apiArg, true)
-	case *clientArg != "":
+	case client.FullCommand():
 		err = d.FetchVersionAction(*clientArg, false)
+	case env.FullCommand():
+		if *envArg == "" && *envApi == "" {
+			kingpin.Fatalf("Either 'auto' or --api must be specified")
+		}
+
+		apiVersion := *envApi
+		if *envArg != "" {
+			apiVersion = *envArg
+		}
+
+		err = d.EnvAction(apiVersion, *envShell)
+	case backups.FullCommand():
+		err = d.BackupsAction(*bkPrune, *bkKeep)
+	case restore.FullCommand():
+		err = d.RestoreAction(*restoreId, *restoreF)
+	case local.FullCommand():
+		err = d.LocalAction(*localArg, *localFmt, workDir())
+	case global.FullCommand():
+		err = d.GlobalAction(*globalArg)
+	case current.FullCommand():
+		err = d.CurrentAction(workDir())
+	case which.FullCommand():
+		err = d.WhichAction(workDir())
+	case shim.FullCommand():
+		if *shimRm {
+			err = d.RemoveShimAction(workDir())
+			break
+		}
+
+		if shimDir := shimDkenvDir(loadShimConfig()); *dkenvDir != shimDir {
+			log.Warningf("The shim only knows about %v - export DKENV_DIR=%v to use %v", shimDir, *dkenvDir, *dkenvDir)
+		}
+
+		err = d.InstallShimAction()
+	case execCmd.FullCommand():
+		err = d.ExecAction(*execArg, *execArgs, *execApi)
+	case shell.FullCommand():
+		if *shellArg == "" && !*shellRm {
+			kingpin.Fatalf("Either a version or --unset must be specified")
+		}
+
+		err = d.ShellAction(*shellArg, *shellType, *shellRm)
+	case hook.FullCommand():
+		err = d.HookAction(*hookArg, *hookAuto)
+	case hookEnv.FullCommand():
+		err = d.HookEnvAction(workDir(), *hookShell, *hookEnvAI)
+	case uninstall.FullCommand():
+		err = d.UninstallAction(*uninstArg, *uninstF)
+	case pruneCmd.FullCommand():
+		var unusedFor time.Duration
+
+		if *pruneAge != "" {
+			if unusedFor, err = lib.ParseAge(*pruneAge); err != nil {
+				break
+			}
+		}
+
+		err = d.PruneAction(*pruneKeep, unusedFor)
+	case use.FullCommand():
+		err = d.UseAction(*useArg)
+	case alias.FullCommand():
+		err = d.AliasAction(*aliasName, *aliasArg, *aliasRm)
+	case history.FullCommand():
+		err = d.HistoryAction(*histLimit)
+	case syncCmd.FullCommand():
+		err = d.SyncAction(workDir())
+	case lockCmd.FullCommand():
+		err = d.LockAction(*lockArg, workDir())
+	case asdfList.FullCommand():
+		err = d.AsdfListAllAction()
+	case asdfLS.FullCommand():
+		err = d.AsdfLatestStableAction(*asdfLSArg)
+	case asdfDL.FullCommand():
+		err = d.AsdfDownloadAction(workDir())
+	case asdfInst.FullCommand():
+		err = d.AsdfInstallAction(workDir())
+	case doctor.FullCommand():
+		err = d.DoctorAction(*docJSON)
 	}
 
 	if err != nil {
-		log.Fatalf(err.Error())
+		log.Fatal(err.Error())
+	}
+}
+
+// Resolve and exec the docker binary for the current directory
+func runShim() {
+	log.SetOutput(os.Stderr)
+
+	settings := loadShimConfig()
+
+	d := lib.New(shimDkenvDir(settings), "")
+	d.DefaultVersion = settings.Get("default_version")
+
+	// Migrating takes a lock; only bother while the store is still flat
+	if d.StoreOutdated() {
+		if err := d.MigrateStore(); err != nil {
+			log.Warningf("dkenv: %v", err)
+		}
 	}
+
+	if err := d.ShimExec(os.Args[1:]); err != nil {
+		log.Fatalf("dkenv: %v", err)
+	}
+}
+
+// Config for the shim, which has no flags. A broken config must not break
+// every docker command, so fall back to ignoring the project config, then to
+// the defaults.
+func loadShimConfig() *cli.Config {
+	settings, err := cli.LoadConfig(nil, workDir())
+	if err == nil {
+		return settings
+	}
+
+	log.Warningf("dkenv: ignoring project config: %v", err)
+
+	settings, err = cli.LoadConfig(nil, "")
+	if err == nil {
+		return settings
+	}
+
+	log.Warningf("dkenv: ignoring config: %v", err)
+
+	return cli.DefaultConfig()
+}
+
+// DkenvDir as seen by the shim; comes from the environment or config files
+func shimDkenvDir(settings *cli.Config) string {
+	home, err := homedir.Dir()
+	if err != nil {
+		log.Fatalf("Unable to fetch current home dir: %v", err)
+	}
+
+	if dir := settings.Get("dkenvdir"); dir != "" {
+		return filepath.Clean(cli.ExpandPath(dir, home))
+	}
+
+	return cli.DefaultDkenvDir(home)
+}
+
+func runConfig() {
+	var err error
+
+	switch command {
+	case cfgGet.FullCommand():
+		err = settings.GetAction(os.Stdout, *cfgGetKey)
+	case cfgList.FullCommand():
+		err = settings.ListAction(os.Stdout)
+	case cfgSet.FullCommand():
+		var path string
+
+		if path, err = settings.SetAction(*cfgSetKey, *cfgSetVal, *cfgScope); err == nil {
+			log.Infof("Set %v to '%v' in %v", *cfgSetKey, *cfgSetVal, path)
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+// Value for cli.LoadConfig; an unset boolean flag is indistinguishable from
+// --flag=false, so only true counts as given
+func boolFlag(value bool) string {
+	if value {
+		return "true"
+	}
+
+	return ""
+}
+
+func workDir() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("Unable to determine current directory: %v", err)
+	}
+
+	return dir
 }